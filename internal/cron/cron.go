@@ -0,0 +1,188 @@
+// Package cron parses standard five-field cron expressions
+// ("minute hour day-of-month month day-of-week") and computes when they fire.
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSearchYears bounds the search for the next tick, so expressions which
+// can never match (e.g. "0 0 30 2 *") are detected instead of looping forever.
+const maxSearchYears = 5
+
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day-of-month", 1, 31},
+	{"month", 1, 12},
+	{"day-of-week", 0, 7},
+}
+
+// Schedule is a parsed cron expression. All times are evaluated in the
+// location of the time passed to Next.
+type Schedule struct {
+	expr string
+
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny record whether the day fields were "*". As in
+	// traditional cron, a day matches either of both fields if both are
+	// restricted.
+	domAny, dowAny bool
+}
+
+// Parse parses a five-field cron expression. Fields support "*", single
+// values, ranges ("1-5"), lists ("1,15") and steps ("*/15", "0-30/10").
+// Day-of-week accepts 0 and 7 for sunday.
+func Parse(expr string) (*Schedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("expected %d fields in cron expression, got %d", len(fields), len(parts))
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, part := range parts {
+		b, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, err
+		}
+
+		bits[i] = b
+	}
+
+	// Sunday may be written as 0 or 7.
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	s := &Schedule{
+		expr:   expr,
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: parts[2] == "*",
+		dowAny: parts[4] == "*",
+	}
+
+	if s.Next(time.Now()).IsZero() {
+		return nil, errors.New("cron expression never matches")
+	}
+
+	return s, nil
+}
+
+func parseField(value string, f field) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(value, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step \"%s\" in %s field", stepPart, f.name)
+			}
+		}
+
+		start, end := f.min, f.max
+		if rangePart != "*" {
+			lo, hi, isRange := strings.Cut(rangePart, "-")
+
+			var err error
+			if start, err = parseValue(lo, f); err != nil {
+				return 0, err
+			}
+
+			switch {
+			case isRange:
+				if end, err = parseValue(hi, f); err != nil {
+					return 0, err
+				}
+			case !hasStep:
+				end = start
+			}
+
+			if start > end {
+				return 0, fmt.Errorf("invalid range \"%s\" in %s field", rangePart, f.name)
+			}
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << i
+		}
+	}
+
+	return bits, nil
+}
+
+func parseValue(value string, f field) (int, error) {
+	v, err := strconv.Atoi(value)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value \"%s\" in %s field, expected %d-%d", value, f.name, f.min, f.max)
+	}
+
+	return v, nil
+}
+
+// Next returns the first time after t at which the schedule fires, or the
+// zero time if it does not fire within the next years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+
+			continue
+		}
+
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+
+			continue
+		}
+
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+
+			continue
+		}
+
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domAny || s.dowAny {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+func (s *Schedule) String() string {
+	return s.expr
+}
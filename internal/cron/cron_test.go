@@ -0,0 +1,114 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"empty", ""},
+		{"too few fields", "0 3 * *"},
+		{"too many fields", "0 3 * * * *"},
+		{"minute out of range", "60 * * * *"},
+		{"hour out of range", "0 24 * * *"},
+		{"day-of-month zero", "0 0 0 * *"},
+		{"month out of range", "0 0 1 13 *"},
+		{"day-of-week out of range", "0 0 * * 8"},
+		{"not a number", "a * * * *"},
+		{"negative value", "-1 * * * *"},
+		{"reversed range", "0 5-3 * * *"},
+		{"zero step", "*/0 * * * *"},
+		{"invalid step", "*/x * * * *"},
+		{"empty list entry", "0,,5 * * * *"},
+		{"never matches", "0 0 30 2 *"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.expr); err == nil {
+				t.Errorf("Parse(%q) succeeded, want an error", tt.expr)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	// 2024-03-13 is a wednesday.
+	from := time.Date(2024, 3, 13, 10, 17, 42, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{"every minute", "* * * * *", time.Date(2024, 3, 13, 10, 18, 0, 0, time.UTC)},
+		{"every 15 minutes", "*/15 * * * *", time.Date(2024, 3, 13, 10, 30, 0, 0, time.UTC)},
+		{"daily at 3am", "0 3 * * *", time.Date(2024, 3, 14, 3, 0, 0, 0, time.UTC)},
+		{"later today", "30 22 * * *", time.Date(2024, 3, 13, 22, 30, 0, 0, time.UTC)},
+		{"list", "5,20 * * * *", time.Date(2024, 3, 13, 10, 20, 0, 0, time.UTC)},
+		{"range with step", "0-30/10 11 * * *", time.Date(2024, 3, 13, 11, 0, 0, 0, time.UTC)},
+		{"value with step", "50/5 * * * *", time.Date(2024, 3, 13, 10, 50, 0, 0, time.UTC)},
+		{"outside business hours", "0 0-6,19-23 * * 1-5", time.Date(2024, 3, 13, 19, 0, 0, 0, time.UTC)},
+		{"day-of-month", "0 0 1 * *", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"month", "0 0 1 6 *", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"next year", "0 0 1 1 *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"sunday as 0", "0 4 * * 0", time.Date(2024, 3, 17, 4, 0, 0, 0, time.UTC)},
+		{"sunday as 7", "0 4 * * 7", time.Date(2024, 3, 17, 4, 0, 0, 0, time.UTC)},
+		{"day-of-month or day-of-week", "0 0 20 * 5", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"leap day", "0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.expr, err)
+			}
+
+			if got := s.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextIsAfterTick(t *testing.T) {
+	s, err := Parse("0 3 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tick := time.Date(2024, 3, 13, 3, 0, 0, 0, time.UTC)
+	if got, want := s.Next(tick), tick.AddDate(0, 0, 1); !got.Equal(want) {
+		t.Errorf("Next() at a tick = %s, want %s", got, want)
+	}
+}
+
+func TestNextKeepsLocation(t *testing.T) {
+	s, err := Parse("0 3 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	got := s.Next(time.Date(2024, 3, 13, 10, 0, 0, 0, loc))
+
+	if want := time.Date(2024, 3, 14, 3, 0, 0, 0, loc); !got.Equal(want) || got.Location() != loc {
+		t.Errorf("Next() = %s, want %s", got, want)
+	}
+}
+
+func TestString(t *testing.T) {
+	s, err := Parse("0 3 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.String(); got != "0 3 * * *" {
+		t.Errorf("String() = %q, want the expression", got)
+	}
+}
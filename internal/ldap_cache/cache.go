@@ -6,41 +6,113 @@ import (
 
 type cacheable interface {
 	DN() string
+	CN() string
 }
 
 type Cache[T cacheable] struct {
 	m     sync.RWMutex
 	items []T
+	// byDN maps DNs to their position in items, so lookups by DN do not hold
+	// the read lock for a scan over the whole directory.
+	byDN map[string]int
+	// previous holds the generation replaced by the last setAll, so changes
+	// between two refreshes can be shown.
+	previous     []T
+	previousByDN map[string]int
+	// filled is set by the first setAll.
+	filled bool
+	// less orders the items on setAll, they are kept in the order returned
+	// by the server when nil.
+	less func(a, b T) bool
+	// shards serves FindByDN without the cache lock when set, see
+	// setIndexShards.
+	shards *shardedIndex[T]
 }
 
 func NewCached[T cacheable]() Cache[T] {
 	return Cache[T]{
 		items: make([]T, 0),
+		byDN:  make(map[string]int),
 	}
 }
 
-func (c *Cache[T]) setAll(v []T) {
+// setIndexShards makes FindByDN use an index split into n shards with a lock
+// each. It has to be called before the cache is filled, n below 2 keeps the
+// single cache lock.
+func (c *Cache[T]) setIndexShards(n int) {
+	if n < 2 {
+		c.shards = nil
+
+		return
+	}
+
+	c.shards = newShardedIndex[T](n)
+}
+
+// setAll replaces all items and returns the previous ones. replaced is false
+// for the initial fill, when there was no previous generation.
+func (c *Cache[T]) setAll(v []T) (previous []T, replaced bool) {
+	// Sorting and the index happen before taking the lock, so readers are
+	// only blocked for swapping the generations.
+	if c.less != nil {
+		sortBy(v, c.less)
+	}
+	byDN := indexByDN(v)
+	var shards []map[string]T
+	if c.shards != nil {
+		shards = c.shards.build(v)
+	}
+
 	c.m.Lock()
 	defer c.m.Unlock()
 
-	c.items = v
+	c.previous, c.previousByDN = c.items, c.byDN
+	c.items, c.byDN = v, byDN
+	if c.shards != nil {
+		c.shards.swap(shards)
+	}
+	replaced, c.filled = c.filled, true
+
+	return c.previous, replaced
+}
+
+func indexByDN[T cacheable](items []T) map[string]int {
+	byDN := make(map[string]int, len(items))
+	for idx, item := range items {
+		byDN[item.DN()] = idx
+	}
+
+	return byDN
 }
 
+// update applies fn to a copy of all items and replaces the current
+// generation with it, so snapshots taken before stay unchanged.
 func (c *Cache[T]) update(fn func(*T)) {
 	c.m.Lock()
 	defer c.m.Unlock()
 
-	for idx, item := range c.items {
-		fn(&item)
-		c.items[idx] = item
+	items := make([]T, len(c.items))
+	copy(items, c.items)
+	for idx := range items {
+		fn(&items[idx])
+	}
+
+	c.items = items
+	if c.shards != nil {
+		c.shards.swap(c.shards.build(items))
 	}
 }
 
+// Get returns a copy of all items, which callers may sort freely without
+// invalidating the index.
 func (c *Cache[T]) Get() []T {
 	c.m.RLock()
 	defer c.m.RUnlock()
 
-	return c.items
+	items := make([]T, len(c.items))
+	copy(items, c.items)
+
+	return items
 }
 
 func (c *Cache[T]) Find(fn func(T) bool) (v *T, found bool) {
@@ -57,9 +129,36 @@ func (c *Cache[T]) Find(fn func(T) bool) (v *T, found bool) {
 }
 
 func (c *Cache[T]) FindByDN(dn string) (v *T, found bool) {
-	return c.Find(func(v T) bool {
-		return v.DN() == dn
-	})
+	if c.shards != nil {
+		return c.shards.find(dn)
+	}
+
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	idx, found := c.byDN[dn]
+	if !found {
+		return nil, false
+	}
+
+	item := c.items[idx]
+
+	return &item, true
+}
+
+// FindPreviousByDN looks up an item in the generation replaced by the last refresh.
+func (c *Cache[T]) FindPreviousByDN(dn string) (v *T, found bool) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	idx, found := c.previousByDN[dn]
+	if !found {
+		return nil, false
+	}
+
+	item := c.previous[idx]
+
+	return &item, true
 }
 
 func (c *Cache[T]) Filter(fn func(T) bool) (v []T) {
@@ -81,3 +180,75 @@ func (c *Cache[T]) Count() int {
 
 	return len(c.items)
 }
+
+// Duplicates returns the DNs of all items sharing a key, for every key that is
+// used by more than one item.
+func (c *Cache[T]) Duplicates(key func(T) string) map[string][]string {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	dns := make(map[string][]string)
+	for _, item := range c.items {
+		k := key(item)
+		dns[k] = append(dns[k], item.DN())
+	}
+
+	for k, v := range dns {
+		if len(v) < 2 {
+			delete(dns, k)
+		}
+	}
+
+	return dns
+}
+
+// Snapshot is an immutable view of one generation of a Cache. Handlers doing
+// several lookups for one request use it, so a refresh in between can not mix
+// two generations.
+type Snapshot[T cacheable] struct {
+	items []T
+	byDN  map[string]int
+}
+
+func (c *Cache[T]) Snapshot() Snapshot[T] {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	return Snapshot[T]{
+		items: c.items,
+		byDN:  c.byDN,
+	}
+}
+
+// Get returns a copy of all items in the snapshot.
+func (s Snapshot[T]) Get() []T {
+	items := make([]T, len(s.items))
+	copy(items, s.items)
+
+	return items
+}
+
+func (s Snapshot[T]) FindByDN(dn string) (v *T, found bool) {
+	idx, found := s.byDN[dn]
+	if !found {
+		return nil, false
+	}
+
+	item := s.items[idx]
+
+	return &item, true
+}
+
+func (s Snapshot[T]) Filter(fn func(T) bool) (v []T) {
+	for _, item := range s.items {
+		if fn(item) {
+			v = append(v, item)
+		}
+	}
+
+	return v
+}
+
+func (s Snapshot[T]) Count() int {
+	return len(s.items)
+}
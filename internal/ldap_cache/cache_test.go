@@ -0,0 +1,183 @@
+package ldap_cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func entries(n int, value string) []entry {
+	items := make([]entry, n)
+	for idx := range items {
+		items[idx] = entry{
+			dn:     fmt.Sprintf("cn=user%d,ou=users,dc=example,dc=com", idx),
+			cn:     fmt.Sprintf("user%d", idx),
+			values: []string{value},
+		}
+	}
+
+	return items
+}
+
+func newTestCache(shards int) *Cache[entry] {
+	c := NewCached[entry]()
+	c.setIndexShards(shards)
+
+	return &c
+}
+
+func TestShardedCacheFindsAllItems(t *testing.T) {
+	for _, shards := range []int{1, 2, 7, 64} {
+		t.Run(fmt.Sprintf("%d shards", shards), func(t *testing.T) {
+			c := newTestCache(shards)
+			items := entries(1000, "first")
+			c.setAll(items)
+
+			for _, item := range items {
+				got, found := c.FindByDN(item.dn)
+				if !found || got.dn != item.dn {
+					t.Fatalf("FindByDN(%q) = %v, %v", item.dn, got, found)
+				}
+			}
+
+			if _, found := c.FindByDN("cn=missing,dc=example,dc=com"); found {
+				t.Error("found an item that is not cached")
+			}
+		})
+	}
+}
+
+func TestShardedCacheFollowsUpdates(t *testing.T) {
+	c := newTestCache(8)
+	c.setAll(entries(100, "first"))
+
+	// The next generation drops the second half.
+	c.setAll(entries(50, "second"))
+	if _, found := c.FindByDN("cn=user75,ou=users,dc=example,dc=com"); found {
+		t.Error("found an item of the previous generation")
+	}
+
+	c.update(func(e *entry) { e.values = []string{"updated"} })
+	for _, item := range entries(50, "") {
+		got, found := c.FindByDN(item.dn)
+		if !found || got.values[0] != "updated" {
+			t.Fatalf("FindByDN(%q) = %v, %v, want the updated item", item.dn, got, found)
+		}
+	}
+
+	// The previous generation and snapshots do not depend on the shards.
+	if _, found := c.FindPreviousByDN("cn=user75,ou=users,dc=example,dc=com"); !found {
+		t.Error("item of the previous generation was not found")
+	}
+	if _, found := c.Snapshot().FindByDN("cn=user25,ou=users,dc=example,dc=com"); !found {
+		t.Error("item was not found in a snapshot")
+	}
+}
+
+func TestSnapshotKeepsGeneration(t *testing.T) {
+	c := newTestCache(1)
+	c.setAll(entries(10, "first"))
+
+	snapshot := c.Snapshot()
+
+	// Neither an optimistic update nor a refresh changes the snapshot.
+	c.update(func(e *entry) { e.values = []string{"updated"} })
+	c.setAll(entries(5, "second"))
+
+	if got := snapshot.Count(); got != 10 {
+		t.Errorf("snapshot has %d items, want 10", got)
+	}
+	for _, item := range snapshot.Get() {
+		if item.values[0] != "first" {
+			t.Fatalf("item %q of the snapshot has %q, want %q", item.dn, item.values[0], "first")
+		}
+	}
+
+	got, found := snapshot.FindByDN("cn=user7,ou=users,dc=example,dc=com")
+	if !found || got.values[0] != "first" {
+		t.Errorf("FindByDN() on the snapshot = %v, %v, want the first generation", got, found)
+	}
+
+	if _, found := c.FindByDN("cn=user7,ou=users,dc=example,dc=com"); found {
+		t.Error("cache still has an item of the first generation")
+	}
+}
+
+func TestSnapshotIsConsistentDuringRefreshes(t *testing.T) {
+	c := newTestCache(1)
+	c.setAll(entries(100, "0"))
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for generation := 1; ; generation++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			c.setAll(entries(100, fmt.Sprint(generation)))
+		}
+	}()
+
+	// All lookups on one snapshot see the same generation.
+	for i := 0; i < 1000; i++ {
+		snapshot := c.Snapshot()
+		want := snapshot.Get()[0].values[0]
+
+		for _, dn := range []string{"cn=user1,ou=users,dc=example,dc=com", "cn=user99,ou=users,dc=example,dc=com"} {
+			got, found := snapshot.FindByDN(dn)
+			if !found || got.values[0] != want {
+				t.Fatalf("FindByDN(%q) on a snapshot of generation %s = %v, %v", dn, want, got, found)
+			}
+		}
+	}
+
+	close(stop)
+	<-done
+}
+
+// BenchmarkFindByDN looks up entries from parallel goroutines while another
+// one replaces the whole cache every millisecond, like a refresh storm does.
+func BenchmarkFindByDN(b *testing.B) {
+	items := entries(10000, "")
+
+	for _, shards := range []int{1, 16} {
+		b.Run(fmt.Sprintf("%d shards", shards), func(b *testing.B) {
+			c := newTestCache(shards)
+			c.setAll(items)
+
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+
+				t := time.NewTicker(time.Millisecond)
+				defer t.Stop()
+
+				for {
+					select {
+					case <-stop:
+						return
+					case <-t.C:
+						c.setAll(append([]entry(nil), items...))
+					}
+				}
+			}()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for idx := 0; pb.Next(); idx++ {
+					c.FindByDN(items[idx%len(items)].dn)
+				}
+			})
+			b.StopTimer()
+
+			close(stop)
+			<-done
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package ldap_cache
+
+import "time"
+
+// Clock is the source of time for the Manager. It defaults to the wall clock
+// and can be replaced to drive refresh timing explicitly.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is the subset of *time.Timer used by the Manager.
+type Timer interface {
+	C() <-chan time.Time
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
@@ -0,0 +1,53 @@
+package ldap_cache
+
+import (
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+// MembershipComparison splits the groups of two users into the ones both are
+// members of and the ones only one of them is a member of.
+type MembershipComparison struct {
+	A      *FullLDAPUser
+	B      *FullLDAPUser
+	Common []ldap.Group
+	OnlyA  []ldap.Group
+	OnlyB  []ldap.Group
+}
+
+func (m *Manager) CompareMemberships(a, b *ldap.User) *MembershipComparison {
+	cmp := &MembershipComparison{
+		A:      m.PopulateGroupsForUser(a),
+		B:      m.PopulateGroupsForUser(b),
+		Common: make([]ldap.Group, 0),
+		OnlyA:  make([]ldap.Group, 0),
+		OnlyB:  make([]ldap.Group, 0),
+	}
+
+	inB := make(map[string]bool, len(cmp.B.Groups))
+	for _, group := range cmp.B.Groups {
+		inB[group.DN()] = true
+	}
+
+	inA := make(map[string]bool, len(cmp.A.Groups))
+	for _, group := range cmp.A.Groups {
+		inA[group.DN()] = true
+
+		if inB[group.DN()] {
+			cmp.Common = append(cmp.Common, group)
+		} else {
+			cmp.OnlyA = append(cmp.OnlyA, group)
+		}
+	}
+
+	for _, group := range cmp.B.Groups {
+		if !inA[group.DN()] {
+			cmp.OnlyB = append(cmp.OnlyB, group)
+		}
+	}
+
+	for _, groups := range [][]ldap.Group{cmp.Common, cmp.OnlyA, cmp.OnlyB} {
+		SortGroups(groups, m.config.SortOrder)
+	}
+
+	return cmp
+}
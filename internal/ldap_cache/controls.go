@@ -0,0 +1,88 @@
+package ldap_cache
+
+import (
+	"fmt"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	goldap "github.com/go-ldap/ldap/v3"
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+// Client is the part of simple-ldap-go the cache is filled with. FindUsers,
+// FindGroups and FindComputers search with fixed filters and no controls, so
+// custom filters and controls are sent in a search on a connection from
+// GetConnection, see filtered.
+type Client interface {
+	FindUsers() ([]ldap.User, error)
+	FindGroups() ([]ldap.Group, error)
+	FindComputers() ([]ldap.Computer, error)
+	GetConnection() (*goldap.Conn, error)
+}
+
+// SearchControl is an LDAP control sent with the searches filling the cache.
+type SearchControl string
+
+const (
+	// ControlServerSideSort sorts the entities by sAMAccountName on the
+	// server (RFC 2891). The cache keeps the order of the server instead of
+	// sorting by SortOrder.
+	ControlServerSideSort SearchControl = "server-side-sort"
+)
+
+// SearchControls are all supported controls.
+var SearchControls = []SearchControl{ControlServerSideSort}
+
+// sortedByServer reports whether the entities are sorted by the server.
+func (c Config) sortedByServer() bool {
+	for _, control := range c.Controls {
+		if control == ControlServerSideSort {
+			return true
+		}
+	}
+
+	return false
+}
+
+// control returns the LDAP control to send.
+func (c SearchControl) control() goldap.Control {
+	switch c {
+	case ControlServerSideSort:
+		return sortControl{attribute: "sAMAccountName"}
+	default:
+		panic(fmt.Sprintf("unknown search control %q", string(c)))
+	}
+}
+
+// sortControl requests server-side sorting by one attribute. It is sent
+// without an ordering rule and as non-critical, so servers without support
+// for sorting answer unsorted instead of failing. go-ldap's
+// ControlServerSideSorting always sends an ordering rule, which an empty one
+// makes invalid.
+type sortControl struct {
+	attribute string
+}
+
+func (c sortControl) GetControlType() string {
+	return goldap.ControlTypeServerSideSorting
+}
+
+func (c sortControl) Encode() *ber.Packet {
+	key := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SortKey")
+	key.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.attribute, "attributeType"))
+
+	keys := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SortKeyList")
+	keys.AppendChild(key)
+
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value")
+	value.AppendChild(keys)
+
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.GetControlType(), "Control Type"))
+	packet.AppendChild(value)
+
+	return packet
+}
+
+func (c sortControl) String() string {
+	return fmt.Sprintf("Control Type: Server Side Sorting (%q) Attribute: %s", c.GetControlType(), c.attribute)
+}
@@ -0,0 +1,43 @@
+package ldap_cache
+
+import (
+	"slices"
+	"testing"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+func TestServerSideSort(t *testing.T) {
+	server, client := newTestServer(t)
+	// The CNs are in the opposite order of the sAMAccountNames.
+	server.AddUser("cn=c,ou=users,dc=example,dc=com", "alice", "alice")
+	server.AddUser("cn=b,ou=users,dc=example,dc=com", "bob", "bob")
+	server.AddUser("cn=a,ou=users,dc=example,dc=com", "carol", "carol")
+
+	m := New(client, Config{
+		BaseDN:    testBaseDN,
+		SortOrder: SortByCN,
+		Controls:  []SearchControl{ControlServerSideSort},
+	})
+	if err := m.RefreshUsers(); err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, 0)
+	for _, user := range m.FindUsers(true) {
+		names = append(names, user.SAMAccountName)
+	}
+	if want := []string{"alice", "bob", "carol", "svc-reader"}; !slices.Equal(names, want) {
+		t.Errorf("users are ordered %v, want %v", names, want)
+	}
+
+	sorted := false
+	for _, req := range server.Requests() {
+		if req.Operation == "search" && req.Filter == defaultUserFilter && slices.Contains(req.Controls, goldap.ControlTypeServerSideSorting) {
+			sorted = true
+		}
+	}
+	if !sorted {
+		t.Errorf("no user search with the sort control, got %+v", server.Requests())
+	}
+}
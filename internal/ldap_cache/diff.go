@@ -0,0 +1,79 @@
+package ldap_cache
+
+import (
+	"strconv"
+
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+// AttributeChange describes a single attribute that differs between two
+// versions of an entity. Multi-valued attributes produce one change per value,
+// with either Old or New being empty.
+type AttributeChange struct {
+	Attribute string
+	Old       string
+	New       string
+}
+
+// DiffUsers lists the attributes that differ between two versions of a user.
+func DiffUsers(old, new ldap.User) []AttributeChange {
+	changes := make([]AttributeChange, 0)
+
+	if old.CN() != new.CN() {
+		changes = append(changes, AttributeChange{"cn", old.CN(), new.CN()})
+	}
+
+	if old.SAMAccountName != new.SAMAccountName {
+		changes = append(changes, AttributeChange{"sAMAccountName", old.SAMAccountName, new.SAMAccountName})
+	}
+
+	if old.Enabled != new.Enabled {
+		changes = append(changes, AttributeChange{"enabled", strconv.FormatBool(old.Enabled), strconv.FormatBool(new.Enabled)})
+	}
+
+	if old.Description != new.Description {
+		changes = append(changes, AttributeChange{"description", old.Description, new.Description})
+	}
+
+	if oldMail, newMail := optional(old.Mail), optional(new.Mail); oldMail != newMail {
+		changes = append(changes, AttributeChange{"mail", oldMail, newMail})
+	}
+
+	return append(changes, diffValues("memberOf", old.Groups, new.Groups)...)
+}
+
+// optional returns the value of an optional attribute, or an empty string if
+// it is not set.
+func optional(v *string) string {
+	if v == nil {
+		return ""
+	}
+
+	return *v
+}
+
+func diffValues(attribute string, old, new []string) []AttributeChange {
+	changes := make([]AttributeChange, 0)
+
+	oldValues := make(map[string]struct{}, len(old))
+	for _, v := range old {
+		oldValues[v] = struct{}{}
+	}
+
+	newValues := make(map[string]struct{}, len(new))
+	for _, v := range new {
+		newValues[v] = struct{}{}
+
+		if _, existed := oldValues[v]; !existed {
+			changes = append(changes, AttributeChange{Attribute: attribute, New: v})
+		}
+	}
+
+	for _, v := range old {
+		if _, exists := newValues[v]; !exists {
+			changes = append(changes, AttributeChange{Attribute: attribute, Old: v})
+		}
+	}
+
+	return changes
+}
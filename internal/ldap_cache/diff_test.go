@@ -0,0 +1,90 @@
+package ldap_cache
+
+import (
+	"reflect"
+	"testing"
+
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestDiffUsers(t *testing.T) {
+	base := ldap.User{
+		Enabled:        true,
+		SAMAccountName: "jdoe",
+		Description:    "Developer",
+		Mail:           ptr("jdoe@example.com"),
+		Groups:         []string{"cn=a,dc=example,dc=com", "cn=b,dc=example,dc=com"},
+	}
+
+	tests := []struct {
+		name   string
+		modify func(u *ldap.User)
+		want   []AttributeChange
+	}{
+		{
+			name:   "unchanged",
+			modify: func(*ldap.User) {},
+			want:   []AttributeChange{},
+		},
+		{
+			name:   "sAMAccountName",
+			modify: func(u *ldap.User) { u.SAMAccountName = "john.doe" },
+			want:   []AttributeChange{{"sAMAccountName", "jdoe", "john.doe"}},
+		},
+		{
+			name:   "disabled",
+			modify: func(u *ldap.User) { u.Enabled = false },
+			want:   []AttributeChange{{"enabled", "true", "false"}},
+		},
+		{
+			name:   "description",
+			modify: func(u *ldap.User) { u.Description = "Team lead" },
+			want:   []AttributeChange{{"description", "Developer", "Team lead"}},
+		},
+		{
+			name:   "mail changed",
+			modify: func(u *ldap.User) { u.Mail = ptr("john.doe@example.com") },
+			want:   []AttributeChange{{"mail", "jdoe@example.com", "john.doe@example.com"}},
+		},
+		{
+			name:   "mail removed",
+			modify: func(u *ldap.User) { u.Mail = nil },
+			want:   []AttributeChange{{"mail", "jdoe@example.com", ""}},
+		},
+		{
+			name:   "group added and removed",
+			modify: func(u *ldap.User) { u.Groups = []string{"cn=b,dc=example,dc=com", "cn=c,dc=example,dc=com"} },
+			want: []AttributeChange{
+				{Attribute: "memberOf", New: "cn=c,dc=example,dc=com"},
+				{Attribute: "memberOf", Old: "cn=a,dc=example,dc=com"},
+			},
+		},
+		{
+			name:   "groups reordered",
+			modify: func(u *ldap.User) { u.Groups = []string{"cn=b,dc=example,dc=com", "cn=a,dc=example,dc=com"} },
+			want:   []AttributeChange{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := base
+			updated.Groups = append([]string(nil), base.Groups...)
+			tt.modify(&updated)
+
+			if got := DiffUsers(base, updated); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffUsers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffUsersBothMailsUnset(t *testing.T) {
+	if got := DiffUsers(ldap.User{}, ldap.User{Mail: ptr("")}); len(got) != 0 {
+		t.Errorf("DiffUsers() = %v, want no changes", got)
+	}
+}
@@ -0,0 +1,81 @@
+package ldap_cache
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrRefreshHeldBack is returned by a refresh that was not applied because it
+// returned too few entries. The refresh counts as failed, so the cache is
+// reported as stale while it serves the previous entries.
+var ErrRefreshHeldBack = errors.New("refresh held back until the next refresh confirms the drop of entries")
+
+// acceptRefresh decides whether a refresh which returned current entries of a
+// kind may replace the previous count. A refresh shrinking the cache by more
+// than Config.MaxDropPercent is only accepted once the next refresh confirms
+// it, so a partial answer of the server does not wipe most of the cache.
+func (m *Manager) acceptRefresh(kind EntityKind, previous, current int) bool {
+	if m.config.MaxDropPercent <= 0 || previous == 0 || current >= previous {
+		m.clearPendingDrop(kind)
+
+		return true
+	}
+
+	drop := float64(previous-current) / float64(previous) * 100
+	if drop <= m.config.MaxDropPercent {
+		m.clearPendingDrop(kind)
+
+		return true
+	}
+
+	m.refreshM.Lock()
+	defer m.refreshM.Unlock()
+
+	if m.pendingDrops[kind] {
+		delete(m.pendingDrops, kind)
+		log.Warn().Msgf("refresh returned %d %ss again, %.0f%% less than the %d before, accepting the drop", current, kind, drop, previous)
+
+		return true
+	}
+
+	m.pendingDrops[kind] = true
+	m.skippedRefreshes++
+	log.Error().Msgf("refresh returned %d %ss, %.0f%% less than the %d before, keeping the previous ones until the next refresh confirms the drop", current, kind, drop, previous)
+
+	return false
+}
+
+func (m *Manager) clearPendingDrop(kind EntityKind) {
+	m.refreshM.Lock()
+	defer m.refreshM.Unlock()
+
+	delete(m.pendingDrops, kind)
+}
+
+// PendingDrops returns the kinds of entities whose last refresh was skipped
+// because it returned too few entries.
+func (m *Manager) PendingDrops() []EntityKind {
+	m.refreshM.RLock()
+	defer m.refreshM.RUnlock()
+
+	kinds := make([]EntityKind, 0, len(m.pendingDrops))
+	for kind := range m.pendingDrops {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		return kinds[i] < kinds[j]
+	})
+
+	return kinds
+}
+
+// SkippedRefreshes returns the amount of refreshes that were skipped since
+// startup because they returned too few entries.
+func (m *Manager) SkippedRefreshes() uint64 {
+	m.refreshM.RLock()
+	defer m.refreshM.RUnlock()
+
+	return m.skippedRefreshes
+}
@@ -0,0 +1,83 @@
+package ldap_cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAcceptRefresh(t *testing.T) {
+	tests := []struct {
+		name              string
+		maxDropPercent    float64
+		previous, current int
+		want              bool
+	}{
+		{"disabled", 0, 100, 1, true},
+		{"initial fill", 50, 0, 100, true},
+		{"grown", 50, 100, 120, true},
+		{"unchanged", 50, 100, 100, true},
+		{"small drop", 50, 100, 90, true},
+		{"drop at the threshold", 50, 100, 50, true},
+		{"drop above the threshold", 50, 100, 49, false},
+		{"everything dropped", 50, 100, 0, false},
+		{"fractional threshold", 12.5, 8, 7, true},
+		{"above fractional threshold", 12.5, 8, 6, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(nil, Config{MaxDropPercent: tt.maxDropPercent})
+
+			if got := m.acceptRefresh(EntityKindUser, tt.previous, tt.current); got != tt.want {
+				t.Errorf("acceptRefresh(%d, %d) = %v, want %v", tt.previous, tt.current, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcceptRefreshConfirmsDropOnNextRefresh(t *testing.T) {
+	m := New(nil, Config{MaxDropPercent: 50})
+
+	if m.acceptRefresh(EntityKindUser, 100, 10) {
+		t.Fatal("first large drop was accepted")
+	}
+	if got, want := m.PendingDrops(), []EntityKind{EntityKindUser}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PendingDrops() = %v, want %v", got, want)
+	}
+	if got := m.SkippedRefreshes(); got != 1 {
+		t.Errorf("SkippedRefreshes() = %d, want 1", got)
+	}
+
+	if !m.acceptRefresh(EntityKindUser, 100, 10) {
+		t.Fatal("confirmed drop was not accepted")
+	}
+	if got := m.PendingDrops(); len(got) != 0 {
+		t.Errorf("PendingDrops() = %v after the confirmation, want none", got)
+	}
+	if got := m.SkippedRefreshes(); got != 1 {
+		t.Errorf("SkippedRefreshes() = %d, want 1", got)
+	}
+}
+
+func TestAcceptRefreshRecoveryClearsPendingDrop(t *testing.T) {
+	m := New(nil, Config{MaxDropPercent: 50})
+
+	m.acceptRefresh(EntityKindGroup, 100, 10)
+	if !m.acceptRefresh(EntityKindGroup, 100, 100) {
+		t.Fatal("recovered refresh was not accepted")
+	}
+
+	// The drop has to be seen twice in a row to be accepted.
+	if m.acceptRefresh(EntityKindGroup, 100, 10) {
+		t.Error("drop after a recovery was accepted without confirmation")
+	}
+}
+
+func TestAcceptRefreshTracksKindsSeparately(t *testing.T) {
+	m := New(nil, Config{MaxDropPercent: 50})
+
+	m.acceptRefresh(EntityKindUser, 100, 10)
+	if m.acceptRefresh(EntityKindGroup, 100, 10) {
+		t.Error("drop of groups was confirmed by a drop of users")
+	}
+}
@@ -0,0 +1,137 @@
+package ldap_cache
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// subscriberBufferSize is the amount of events a subscriber can lag behind
+// before further events are dropped for it.
+const subscriberBufferSize = 64
+
+type EntityKind string
+
+const (
+	EntityKindUser     EntityKind = "user"
+	EntityKindGroup    EntityKind = "group"
+	EntityKindComputer EntityKind = "computer"
+)
+
+type CacheEventType string
+
+const (
+	EntityAdded   CacheEventType = "added"
+	EntityUpdated CacheEventType = "updated"
+	EntityRemoved CacheEventType = "removed"
+)
+
+type CacheEvent struct {
+	Type CacheEventType
+	Kind EntityKind
+	DN   string
+}
+
+type subscribers struct {
+	m       sync.Mutex
+	chans   map[chan CacheEvent]struct{}
+	dropped atomic.Uint64
+}
+
+func (s *subscribers) add() chan CacheEvent {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.chans == nil {
+		s.chans = make(map[chan CacheEvent]struct{})
+	}
+
+	ch := make(chan CacheEvent, subscriberBufferSize)
+	s.chans[ch] = struct{}{}
+
+	return ch
+}
+
+func (s *subscribers) remove(ch chan CacheEvent) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if _, exists := s.chans[ch]; !exists {
+		return
+	}
+
+	delete(s.chans, ch)
+	close(ch)
+}
+
+func (s *subscribers) empty() bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return len(s.chans) == 0
+}
+
+// publish hands the events to every subscriber without blocking. Events that
+// do not fit into a subscriber's buffer are dropped and counted.
+func (s *subscribers) publish(events []CacheEvent) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for ch := range s.chans {
+		for _, event := range events {
+			select {
+			case ch <- event:
+			default:
+				s.dropped.Add(1)
+			}
+		}
+	}
+}
+
+// Subscribe returns a channel receiving an event for every entity that was added,
+// updated or removed between two cache refreshes, and a function to unsubscribe.
+// The initial fill of the cache is not reported. Slow subscribers do not block
+// the cache, events are dropped for them instead.
+func (m *Manager) Subscribe() (<-chan CacheEvent, func()) {
+	ch := m.subscribers.add()
+
+	return ch, func() {
+		m.subscribers.remove(ch)
+	}
+}
+
+// DroppedEvents returns the amount of events that were dropped because a
+// subscriber did not keep up.
+func (m *Manager) DroppedEvents() uint64 {
+	return m.subscribers.dropped.Load()
+}
+
+func diffEntities[T cacheable](kind EntityKind, previous, current []T) []CacheEvent {
+	events := make([]CacheEvent, 0)
+
+	old := make(map[string]T, len(previous))
+	for _, item := range previous {
+		old[item.DN()] = item
+	}
+
+	for _, item := range current {
+		prev, existed := old[item.DN()]
+		if !existed {
+			events = append(events, CacheEvent{Type: EntityAdded, Kind: kind, DN: item.DN()})
+
+			continue
+		}
+
+		delete(old, item.DN())
+
+		if !reflect.DeepEqual(prev, item) {
+			events = append(events, CacheEvent{Type: EntityUpdated, Kind: kind, DN: item.DN()})
+		}
+	}
+
+	for dn := range old {
+		events = append(events, CacheEvent{Type: EntityRemoved, Kind: kind, DN: dn})
+	}
+
+	return events
+}
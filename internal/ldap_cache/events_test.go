@@ -0,0 +1,120 @@
+package ldap_cache
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// entry is a minimal cacheable entity, as the entities of simple-ldap-go can
+// not be created with a DN outside of it.
+type entry struct {
+	dn, cn string
+	values []string
+}
+
+func (e entry) DN() string {
+	return e.dn
+}
+
+func (e entry) CN() string {
+	return e.cn
+}
+
+func sortEvents(events []CacheEvent) {
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Type != events[j].Type {
+			return events[i].Type < events[j].Type
+		}
+
+		return events[i].DN < events[j].DN
+	})
+}
+
+func TestDiffEntities(t *testing.T) {
+	previous := []entry{
+		{dn: "cn=a", cn: "a"},
+		{dn: "cn=b", cn: "b", values: []string{"1"}},
+		{dn: "cn=c", cn: "c"},
+		{dn: "cn=d", cn: "d"},
+	}
+
+	tests := []struct {
+		name    string
+		current []entry
+		want    []CacheEvent
+	}{
+		{
+			name:    "unchanged",
+			current: previous,
+			want:    []CacheEvent{},
+		},
+		{
+			name: "reordered",
+			current: []entry{
+				previous[3], previous[2], previous[1], previous[0],
+			},
+			want: []CacheEvent{},
+		},
+		{
+			name: "added, updated and removed",
+			current: []entry{
+				{dn: "cn=a", cn: "a"},
+				{dn: "cn=b", cn: "b", values: []string{"1", "2"}},
+				{dn: "cn=c", cn: "renamed"},
+				{dn: "cn=e", cn: "e"},
+			},
+			want: []CacheEvent{
+				{Type: EntityAdded, Kind: EntityKindUser, DN: "cn=e"},
+				{Type: EntityRemoved, Kind: EntityKindUser, DN: "cn=d"},
+				{Type: EntityUpdated, Kind: EntityKindUser, DN: "cn=b"},
+				{Type: EntityUpdated, Kind: EntityKindUser, DN: "cn=c"},
+			},
+		},
+		{
+			name:    "all removed",
+			current: nil,
+			want: []CacheEvent{
+				{Type: EntityRemoved, Kind: EntityKindUser, DN: "cn=a"},
+				{Type: EntityRemoved, Kind: EntityKindUser, DN: "cn=b"},
+				{Type: EntityRemoved, Kind: EntityKindUser, DN: "cn=c"},
+				{Type: EntityRemoved, Kind: EntityKindUser, DN: "cn=d"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffEntities(EntityKindUser, previous, tt.current)
+			sortEvents(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffEntities() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublishDropsForFullSubscribers(t *testing.T) {
+	var s subscribers
+	ch := s.add()
+
+	events := make([]CacheEvent, subscriberBufferSize+3)
+	s.publish(events)
+
+	if got := len(ch); got != subscriberBufferSize {
+		t.Errorf("subscriber received %d events, want %d", got, subscriberBufferSize)
+	}
+	if got := s.dropped.Load(); got != 3 {
+		t.Errorf("dropped %d events, want 3", got)
+	}
+
+	s.remove(ch)
+	if !s.empty() {
+		t.Error("subscriber was not removed")
+	}
+	// The buffered events stay readable, the channel is closed after them,
+	// which ends the loop.
+	for range ch {
+	}
+}
@@ -0,0 +1,89 @@
+package ldap_cache
+
+import (
+	"strings"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+// searchPageSize is the page size of the searches run by the cache itself,
+// below the default MaxPageSize of ActiveDirectory.
+const searchPageSize = 1000
+
+// Filters used by simple-ldap-go, which are searched with when only controls
+// are configured.
+const (
+	defaultUserFilter     = "(objectClass=user)"
+	defaultGroupFilter    = "(objectClass=group)"
+	defaultComputerFilter = "(objectClass=computer)"
+)
+
+// matchingDNs returns the DNs of the objects below the base DN matching
+// filter, in the order of the server. Only the DNs are requested, the
+// entities themselves are fetched by simple-ldap-go.
+func (m *Manager) matchingDNs(filter string) ([]string, error) {
+	conn, err := m.client.GetConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	controls := make([]goldap.Control, 0, len(m.config.Controls))
+	for _, control := range m.config.Controls {
+		controls = append(controls, control.control())
+	}
+
+	res, err := conn.SearchWithPaging(&goldap.SearchRequest{
+		BaseDN:       m.config.BaseDN,
+		Scope:        goldap.ScopeWholeSubtree,
+		DerefAliases: goldap.NeverDerefAliases,
+		Filter:       filter,
+		// "1.1" requests no attributes (RFC 4511, section 4.5.1.8).
+		Attributes: []string{"1.1"},
+		Controls:   controls,
+	}, searchPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	dns := make([]string, 0, len(res.Entries))
+	for _, entry := range res.Entries {
+		dns = append(dns, entry.DN)
+	}
+
+	return dns, nil
+}
+
+// filtered keeps the entities matching filter, in the order the server
+// returned them for the configured controls. Without a filter and controls,
+// the entities are returned as they are. simple-ldap-go searches with fixed
+// filters and no controls, so they are applied by a second search for the
+// DNs of the matching objects, falling back to defaultFilter.
+func filtered[T cacheable](m *Manager, filter, defaultFilter string, entities []T) ([]T, error) {
+	if filter == "" && len(m.config.Controls) == 0 {
+		return entities, nil
+	}
+
+	if filter == "" {
+		filter = defaultFilter
+	}
+
+	dns, err := m.matchingDNs(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	byDN := make(map[string]T, len(entities))
+	for _, entity := range entities {
+		byDN[strings.ToLower(entity.DN())] = entity
+	}
+
+	matching := make([]T, 0, len(dns))
+	for _, dn := range dns {
+		if entity, exists := byDN[strings.ToLower(dn)]; exists {
+			matching = append(matching, entity)
+		}
+	}
+
+	return matching, nil
+}
@@ -0,0 +1,93 @@
+package ldap_cache
+
+import (
+	"testing"
+
+	"github.com/netresearch/ldap-manager/internal/ldaptest"
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+const (
+	testBaseDN   = "dc=example,dc=com"
+	testReaderDN = "cn=reader,ou=service,dc=example,dc=com"
+)
+
+// newTestServer returns an LDAP server with the readonly user and a client
+// bound as it.
+func newTestServer(t *testing.T) (*ldaptest.Server, *ldap.LDAP) {
+	t.Helper()
+
+	server := ldaptest.NewServer(t, testBaseDN)
+	server.AddUser(testReaderDN, "svc-reader", "secret")
+
+	client, err := ldap.New(ldap.Config{Server: server.URL, BaseDN: testBaseDN, IsActiveDirectory: true}, testReaderDN, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return server, client
+}
+
+func TestRefreshUsesCustomFilters(t *testing.T) {
+	server, client := newTestServer(t)
+	server.AddUser("cn=jdoe,ou=users,dc=example,dc=com", "jdoe", "jdoe")
+	server.AddGroup("cn=admins,ou=groups,dc=example,dc=com")
+	server.AddGroup("cn=legacy,ou=disabled,dc=example,dc=com")
+	server.AddComputer("cn=desktop,ou=computers,dc=example,dc=com", "DESKTOP$")
+
+	config := Config{
+		BaseDN:         testBaseDN,
+		UserFilter:     "(&(objectClass=user)(!(sAMAccountName=svc-*)))",
+		GroupFilter:    "(cn=admins)",
+		ComputerFilter: "(objectClass=computer)",
+	}
+	m := New(client, config)
+
+	if err := m.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Computers are users as well, and are only excluded by the filter of
+	// the users if it says so.
+	if got := m.Users.Count(); got != 2 {
+		t.Errorf("cached %d users, want 2", got)
+	}
+	if _, err := m.FindUserBySAMAccountName("svc-reader"); err == nil {
+		t.Error("service account was cached")
+	}
+
+	groups := m.FindGroups()
+	if len(groups) != 1 || groups[0].CN() != "admins" {
+		t.Errorf("cached groups = %v, want admins", groups)
+	}
+
+	if got := m.Computers.Count(); got != 1 {
+		t.Errorf("cached %d computers, want 1", got)
+	}
+
+	filters := make(map[string]bool)
+	for _, req := range server.Requests() {
+		if req.Operation == "search" && req.DN == testBaseDN {
+			filters[req.Filter] = true
+		}
+	}
+	for _, filter := range []string{config.UserFilter, config.GroupFilter, config.ComputerFilter} {
+		if !filters[filter] {
+			t.Errorf("no search with the filter %s, got %v", filter, filters)
+		}
+	}
+}
+
+func TestRefreshWithoutCustomFilters(t *testing.T) {
+	server, client := newTestServer(t)
+	m := New(client, Config{BaseDN: testBaseDN})
+
+	if err := m.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the searches of simple-ldap-go are sent.
+	if got := server.Count("search"); got != 3 {
+		t.Errorf("sent %d searches, want 3", got)
+	}
+}
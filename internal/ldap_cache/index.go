@@ -0,0 +1,74 @@
+package ldap_cache
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// shardedIndex splits the DN lookups of a Cache over shards keyed by a hash
+// of the DN, each with its own lock. Lookups then neither contend with each
+// other nor with readers holding the cache lock for a whole list.
+type shardedIndex[T cacheable] struct {
+	shards []indexShard[T]
+}
+
+type indexShard[T cacheable] struct {
+	m    sync.RWMutex
+	byDN map[string]T
+}
+
+func newShardedIndex[T cacheable](n int) *shardedIndex[T] {
+	s := &shardedIndex[T]{shards: make([]indexShard[T], n)}
+	for idx := range s.shards {
+		s.shards[idx].byDN = make(map[string]T)
+	}
+
+	return s
+}
+
+func (s *shardedIndex[T]) shardOf(dn string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(dn))
+
+	return int(h.Sum32() % uint32(len(s.shards)))
+}
+
+// build returns the content of every shard for items. It does not touch the
+// shards, so it can run before taking any lock.
+func (s *shardedIndex[T]) build(items []T) []map[string]T {
+	maps := make([]map[string]T, len(s.shards))
+	for idx := range maps {
+		maps[idx] = make(map[string]T, len(items)/len(s.shards)+1)
+	}
+
+	for _, item := range items {
+		maps[s.shardOf(item.DN())][item.DN()] = item
+	}
+
+	return maps
+}
+
+// swap replaces the content of all shards with the result of build.
+func (s *shardedIndex[T]) swap(maps []map[string]T) {
+	for idx := range s.shards {
+		shard := &s.shards[idx]
+
+		shard.m.Lock()
+		shard.byDN = maps[idx]
+		shard.m.Unlock()
+	}
+}
+
+func (s *shardedIndex[T]) find(dn string) (v *T, found bool) {
+	shard := &s.shards[s.shardOf(dn)]
+
+	shard.m.RLock()
+	defer shard.m.RUnlock()
+
+	item, found := shard.byDN[dn]
+	if !found {
+		return nil, false
+	}
+
+	return &item, true
+}
@@ -0,0 +1,175 @@
+package ldap_cache
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+// ldifLineLength is the length after which LDIF lines are folded (RFC 2849).
+const ldifLineLength = 76
+
+// ldifWriter writes LDIF records, remembering the first error so the callers
+// only have to check it once at the end.
+type ldifWriter struct {
+	w   *bufio.Writer
+	err error
+	// allowed holds the lowercased names of the attributes to write, all
+	// attributes are written when nil.
+	allowed map[string]bool
+}
+
+func newLDIFWriter(w io.Writer, attributes []string) *ldifWriter {
+	l := &ldifWriter{w: bufio.NewWriter(w)}
+	if len(attributes) > 0 {
+		l.allowed = make(map[string]bool, len(attributes))
+		for _, attribute := range attributes {
+			l.allowed[strings.ToLower(attribute)] = true
+		}
+	}
+
+	return l
+}
+
+// allows reports whether the attribute is written. Attribute names are case
+// insensitive, and the DN is always written.
+func (l *ldifWriter) allows(name string) bool {
+	return l.allowed == nil || name == "dn" || l.allowed[strings.ToLower(name)]
+}
+
+// safeString reports whether v can be written as is, otherwise it has to be
+// base64 encoded (RFC 2849 SAFE-STRING).
+func safeString(v string) bool {
+	if v == "" {
+		return true
+	}
+
+	if v[0] == ' ' || v[0] == ':' || v[0] == '<' || v[len(v)-1] == ' ' {
+		return false
+	}
+
+	for i := 0; i < len(v); i++ {
+		if c := v[i]; c == 0 || c == '\n' || c == '\r' || c > 127 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (l *ldifWriter) line(s string) {
+	if l.err != nil {
+		return
+	}
+
+	for len(s) > ldifLineLength {
+		if _, l.err = l.w.WriteString(s[:ldifLineLength] + "\n "); l.err != nil {
+			return
+		}
+
+		s = s[ldifLineLength:]
+	}
+
+	_, l.err = l.w.WriteString(s + "\n")
+}
+
+func (l *ldifWriter) attribute(name string, values ...string) {
+	if !l.allows(name) {
+		return
+	}
+
+	for _, v := range values {
+		if safeString(v) {
+			l.line(name + ": " + v)
+		} else {
+			l.line(name + ":: " + base64.StdEncoding.EncodeToString([]byte(v)))
+		}
+	}
+}
+
+func (l *ldifWriter) comment(s string) {
+	l.line("# " + s)
+}
+
+// enabled writes the enabled state derived from userAccountControl.
+func (l *ldifWriter) enabled(enabled bool) {
+	if l.allows("userAccountControl") {
+		l.comment(fmt.Sprintf("enabled: %v", enabled))
+	}
+}
+
+func (l *ldifWriter) end() {
+	l.line("")
+}
+
+// ExportLDIF writes all cached users, groups and computers as LDIF records.
+// Only the attributes held by the cache are exported, and if attributes is
+// not empty, only those of them. Whether an account is enabled is derived
+// from userAccountControl, so it is written as a comment if that attribute
+// is exported. The records are written from a snapshot of each cache while
+// being streamed, so the export does not need to be held in memory.
+func (m *Manager) ExportLDIF(w io.Writer, attributes []string) error {
+	l := newLDIFWriter(w, attributes)
+
+	l.line("version: 1")
+	l.end()
+
+	for _, user := range m.Users.Snapshot().items {
+		exportUser(l, user)
+	}
+
+	for _, group := range m.Groups.Snapshot().items {
+		exportGroup(l, group)
+	}
+
+	for _, computer := range m.Computers.Snapshot().items {
+		exportComputer(l, computer)
+	}
+
+	if l.err != nil {
+		return l.err
+	}
+
+	return l.w.Flush()
+}
+
+func exportUser(l *ldifWriter, user ldap.User) {
+	l.attribute("dn", user.DN())
+	l.enabled(user.Enabled)
+	l.attribute("cn", user.CN())
+	l.attribute("sAMAccountName", user.SAMAccountName)
+	if user.Description != "" {
+		l.attribute("description", user.Description)
+	}
+	if user.Mail != nil {
+		l.attribute("mail", *user.Mail)
+	}
+	l.attribute("memberOf", user.Groups...)
+	l.end()
+}
+
+func exportGroup(l *ldifWriter, group ldap.Group) {
+	l.attribute("dn", group.DN())
+	l.attribute("cn", group.CN())
+	l.attribute("member", group.Members...)
+	l.end()
+}
+
+func exportComputer(l *ldifWriter, computer ldap.Computer) {
+	l.attribute("dn", computer.DN())
+	l.enabled(computer.Enabled)
+	l.attribute("cn", computer.CN())
+	l.attribute("sAMAccountName", computer.SAMAccountName)
+	if computer.OS != "" {
+		l.attribute("operatingSystem", computer.OS)
+	}
+	if computer.OSVersion != "" {
+		l.attribute("operatingSystemVersion", computer.OSVersion)
+	}
+	l.attribute("memberOf", computer.Groups...)
+	l.end()
+}
@@ -0,0 +1,162 @@
+package ldap_cache
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+func writeLDIF(t *testing.T, attributes []string, fn func(l *ldifWriter)) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	l := newLDIFWriter(&buf, attributes)
+	fn(l)
+
+	if l.err != nil {
+		t.Fatal(l.err)
+	}
+	if err := l.w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.String()
+}
+
+func TestSafeString(t *testing.T) {
+	tests := []struct {
+		value string
+		safe  bool
+	}{
+		{"", true},
+		{"John Doe", true},
+		{"cn=a,dc=example,dc=com", true},
+		{"a:b<c", true},
+		{" leading space", false},
+		{"trailing space ", false},
+		{":colon", false},
+		{"<less than", false},
+		{"line\nbreak", false},
+		{"carriage\rreturn", false},
+		{"nul\x00", false},
+		{"Jürgen", false},
+	}
+
+	for _, tt := range tests {
+		if got := safeString(tt.value); got != tt.safe {
+			t.Errorf("safeString(%q) = %v, want %v", tt.value, got, tt.safe)
+		}
+	}
+}
+
+func TestLDIFAttribute(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   string
+	}{
+		{"safe", []string{"John Doe"}, "cn: John Doe\n"},
+		{"empty", []string{""}, "cn: \n"},
+		{"multiple values", []string{"a", "b"}, "cn: a\ncn: b\n"},
+		{"no values", nil, ""},
+		{"leading space", []string{" John"}, "cn:: IEpvaG4=\n"},
+		{"non-ASCII", []string{"Jürgen"}, "cn:: SsO8cmdlbg==\n"},
+		{"line break", []string{"a\nb"}, "cn:: YQpi\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := writeLDIF(t, nil, func(l *ldifWriter) {
+				l.attribute("cn", tt.values...)
+			})
+
+			if got != tt.want {
+				t.Errorf("attribute() wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLDIFFoldsLongLines(t *testing.T) {
+	value := strings.Repeat("x", 200)
+	got := writeLDIF(t, nil, func(l *ldifWriter) {
+		l.attribute("description", value)
+	})
+
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("wrote %d lines, want 3: %q", len(lines), got)
+	}
+
+	unfolded := lines[0]
+	for _, line := range lines {
+		if len(line) > ldifLineLength+1 {
+			t.Errorf("line %q is longer than %d characters", line, ldifLineLength+1)
+		}
+	}
+	for _, line := range lines[1:] {
+		if !strings.HasPrefix(line, " ") {
+			t.Fatalf("continuation line %q does not start with a space", line)
+		}
+		unfolded += line[1:]
+	}
+
+	if want := "description: " + value; unfolded != want {
+		t.Errorf("unfolded line = %q, want %q", unfolded, want)
+	}
+}
+
+func TestLDIFAllowList(t *testing.T) {
+	user := ldap.User{
+		Enabled:        true,
+		SAMAccountName: "jdoe",
+		Description:    "Developer",
+		Mail:           ptr("jdoe@example.com"),
+		Groups:         []string{"cn=a,dc=example,dc=com"},
+	}
+
+	tests := []struct {
+		name       string
+		attributes []string
+		want       []string
+		missing    []string
+	}{
+		{
+			name: "all attributes",
+			want: []string{"dn: ", "# enabled: true", "sAMAccountName: jdoe", "description: Developer", "mail: jdoe@example.com", "memberOf: cn=a,dc=example,dc=com"},
+		},
+		{
+			name:       "allow-list",
+			attributes: []string{"samaccountname", "memberOf"},
+			want:       []string{"dn: ", "sAMAccountName: jdoe", "memberOf: cn=a,dc=example,dc=com"},
+			missing:    []string{"# enabled", "description:", "mail:", "cn:"},
+		},
+		{
+			name:       "enabled state",
+			attributes: []string{"userAccountControl"},
+			want:       []string{"dn: ", "# enabled: true"},
+			missing:    []string{"sAMAccountName:", "memberOf:"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := writeLDIF(t, tt.attributes, func(l *ldifWriter) {
+				exportUser(l, user)
+			})
+
+			for _, line := range tt.want {
+				if !strings.Contains(got, line+"\n") {
+					t.Errorf("export does not contain %q:\n%s", line, got)
+				}
+			}
+			for _, line := range tt.missing {
+				if strings.Contains(got, line) {
+					t.Errorf("export contains %q:\n%s", line, got)
+				}
+			}
+		})
+	}
+}
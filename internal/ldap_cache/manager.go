@@ -1,16 +1,84 @@
 package ldap_cache
 
 import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/netresearch/ldap-manager/internal/cron"
 	ldap "github.com/netresearch/simple-ldap-go"
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	refreshInterval   = 30 * time.Second
+	maxRefreshBackoff = 10 * time.Minute
+
+	// warmupRetries bounds how often the first refresh is retried while the
+	// server is busy, starting with warmupBackoff between the attempts.
+	warmupRetries = 3
+	warmupBackoff = time.Second
+)
+
+type Config struct {
+	// SlowOperationThreshold is the duration above which LDAP operations are
+	// logged as slow. 0 disables the logging.
+	SlowOperationThreshold time.Duration
+	// Schedule replaces the fixed refresh interval when set. Failed refreshes
+	// are still retried with backoff.
+	Schedule *cron.Schedule
+	// Stagger spreads the refreshes of users, groups and computers evenly
+	// over the refresh interval instead of running them back to back. It has
+	// no effect together with Schedule.
+	Stagger bool
+	// Clock defaults to the wall clock when nil.
+	Clock Clock
+	// SortOrder is the order lists are returned in, it defaults to SortByCN.
+	// It is ignored when the server sorts, see ControlServerSideSort.
+	SortOrder SortOrder
+	// MaxDropPercent is the share of entries a refresh may lose before it is
+	// held back until the next refresh confirms it. 0 disables the check.
+	MaxDropPercent float64
+	// IndexShards splits the DN index of each cache into that many shards
+	// with a lock each. Below 2, lookups share the lock of the cache.
+	IndexShards int
+	// BaseDN is searched for the objects matching the filters below.
+	BaseDN string
+	// UserFilter, GroupFilter and ComputerFilter restrict the cached
+	// entities to the objects matching them. Only objects found by the
+	// default filters of simple-ldap-go are considered, e.g.
+	// (objectClass=user) for users. Empty filters keep all entities.
+	UserFilter     string
+	GroupFilter    string
+	ComputerFilter string
+	// Controls are sent with a search for the DNs of the entities, whose
+	// order is kept. See SearchControl.
+	Controls []SearchControl
+}
+
 type Manager struct {
 	stop chan struct{}
 
-	client *ldap.LDAP
+	client Client
+	config Config
+
+	refreshM                 sync.RWMutex
+	lastRefresh              time.Time
+	duplicateSAMAccountNames []string
+	pendingDrops             map[EntityKind]bool
+	skippedRefreshes         uint64
+	// refreshFailing is set while refreshes fail, so the cache serves the
+	// data of the last successful refresh.
+	refreshFailing bool
+
+	subscribers subscribers
+
+	// membershipM serializes the optimistic membership updates, so the user
+	// and the group side of two concurrent updates can not interleave.
+	membershipM sync.Mutex
 
 	Users     Cache[ldap.User]
 	Groups    Cache[ldap.Group]
@@ -20,32 +88,67 @@ type Manager struct {
 type FullLDAPUser struct {
 	ldap.User
 	Groups []ldap.Group
+	// Changes lists the attributes that changed since the previous cache generation.
+	Changes []AttributeChange
+	// Unresolved counts the group DNs which could not be found in the cache.
+	Unresolved int
 }
 
 type FullLDAPGroup struct {
 	ldap.Group
 	Members []ldap.User
+	// Unresolved counts the member DNs which could not be found in the cache.
+	Unresolved int
 }
 
 type FullLDAPComputer struct {
 	ldap.Computer
 	Groups []ldap.Group
+	// Unresolved counts the group DNs which could not be found in the cache.
+	Unresolved int
 }
 
-func New(client *ldap.LDAP) *Manager {
-	return &Manager{
-		stop:      make(chan struct{}),
-		client:    client,
-		Users:     NewCached[ldap.User](),
-		Groups:    NewCached[ldap.Group](),
-		Computers: NewCached[ldap.Computer](),
+func New(client Client, config Config) *Manager {
+	if config.Clock == nil {
+		config.Clock = realClock{}
+	}
+
+	m := &Manager{
+		stop:         make(chan struct{}),
+		client:       client,
+		config:       config,
+		pendingDrops: make(map[EntityKind]bool),
+		Users:        NewCached[ldap.User](),
+		Groups:       NewCached[ldap.Group](),
+		Computers:    NewCached[ldap.Computer](),
+	}
+	// The order of a server-side sort is kept.
+	if !config.sortedByServer() {
+		m.Users.less = userLess(config.SortOrder)
+		m.Groups.less = groupLess(config.SortOrder)
+		m.Computers.less = computerLess(config.SortOrder)
 	}
+	m.Users.setIndexShards(config.IndexShards)
+	m.Groups.setIndexShards(config.IndexShards)
+	m.Computers.setIndexShards(config.IndexShards)
+
+	return m
 }
 
 func (m *Manager) Run() {
-	t := time.NewTicker(30 * time.Second)
+	// The first refresh always fetches everything at once, so the cache is
+	// complete as soon as possible.
+	failed, stopped := m.warmup()
+	if stopped {
+		log.Info().Msg("LDAP cache stopped")
+
+		return
+	}
 
-	m.Refresh()
+	m.setRefreshFailing(failed)
+	delay := m.nextDelay(m.refreshInterval(), failed)
+	t := m.config.Clock.NewTimer(delay)
+	step := 0
 
 	for {
 		select {
@@ -54,63 +157,311 @@ func (m *Manager) Run() {
 			log.Info().Msg("LDAP cache stopped")
 
 			return
-		case <-t.C:
-			m.Refresh()
+		case <-t.C():
+			var err error
+			if m.staggered() {
+				step, err = m.refreshStep(step)
+			} else {
+				err = m.Refresh()
+			}
+
+			m.setRefreshFailing(err != nil)
+			delay = m.nextDelay(delay, err != nil)
+			t.Reset(delay)
+		}
+	}
+}
+
+// warmup runs the first refresh. While the server reports itself as busy or
+// unavailable it is retried a few times with a short backoff, so a brief busy
+// period at startup does not delay readiness by a whole refresh interval.
+func (m *Manager) warmup() (failed, stopped bool) {
+	backoff := warmupBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := m.Refresh()
+		if err == nil {
+			return false, false
+		}
+
+		if attempt == warmupRetries || !isTransient(err) {
+			return true, false
 		}
+
+		log.Warn().Err(err).Msgf("LDAP server is busy during warmup, retrying in %s", backoff)
+
+		t := m.config.Clock.NewTimer(backoff)
+		select {
+		case <-m.stop:
+			t.Stop()
+
+			return true, true
+		case <-t.C():
+		}
+
+		backoff *= 2
 	}
 }
 
+// isTransient reports whether err is caused by a server which is temporarily
+// unable to answer.
+func isTransient(err error) bool {
+	var ldapErr *goldap.Error
+	if !errors.As(err, &ldapErr) {
+		return false
+	}
+
+	return ldapErr.ResultCode == goldap.LDAPResultBusy || ldapErr.ResultCode == goldap.LDAPResultUnavailable
+}
+
+func (m *Manager) staggered() bool {
+	return m.config.Stagger && m.config.Schedule == nil
+}
+
+// refreshInterval is the time between two refreshes, which is a third of the
+// regular interval when each refresh only fetches one entity type.
+func (m *Manager) refreshInterval() time.Duration {
+	if m.staggered() {
+		return refreshInterval / 3
+	}
+
+	return refreshInterval
+}
+
+// refreshStep refreshes a single entity type of a staggered refresh and
+// returns the step to run next. A failed step is retried, so the last refresh
+// time is updated whenever a round completes.
+func (m *Manager) refreshStep(step int) (int, error) {
+	steps := []func() error{m.RefreshUsers, m.RefreshGroups, m.RefreshComputers}
+
+	if err := steps[step](); err != nil {
+		log.Error().Err(err).Send()
+
+		return step, err
+	}
+
+	if step < len(steps)-1 {
+		return step + 1, nil
+	}
+
+	log.Debug().Msgf("Refreshed LDAP cache with %d users, %d groups and %d computers", m.Users.Count(), m.Groups.Count(), m.Computers.Count())
+
+	m.refreshM.Lock()
+	m.lastRefresh = m.config.Clock.Now()
+	m.refreshM.Unlock()
+
+	return 0, nil
+}
+
+// nextDelay returns the time until the next refresh, which is the next tick
+// of the configured schedule unless the refresh failed.
+func (m *Manager) nextDelay(current time.Duration, failed bool) time.Duration {
+	if failed || m.config.Schedule == nil {
+		return nextRefreshDelay(m.refreshInterval(), current, failed)
+	}
+
+	now := m.config.Clock.Now()
+	next := m.config.Schedule.Next(now)
+	log.Debug().Msgf("next LDAP cache refresh scheduled at %s", next)
+
+	return next.Sub(now)
+}
+
+// nextRefreshDelay doubles the delay after a failed refresh, up to maxRefreshBackoff,
+// and falls back to the regular refresh interval once a refresh succeeds again.
+func nextRefreshDelay(interval, current time.Duration, failed bool) time.Duration {
+	if !failed {
+		return interval
+	}
+
+	next := current * 2
+	if next > maxRefreshBackoff {
+		next = maxRefreshBackoff
+	}
+
+	log.Warn().Msgf("LDAP cache refresh failed, retrying in %s", next)
+
+	return next
+}
+
 func (m *Manager) Stop() {
 	m.stop <- struct{}{}
 }
 
 func (m *Manager) RefreshUsers() error {
-	users, err := m.client.FindUsers()
+	var users []ldap.User
+	err := TimeOperation(m.config.SlowOperationThreshold, "find users", "", func() (err error) {
+		users, err = m.client.FindUsers()
+		if err != nil {
+			return err
+		}
+
+		users, err = filtered(m, m.config.UserFilter, defaultUserFilter, users)
+
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
-	m.Users.setAll(users)
+	if !m.acceptRefresh(EntityKindUser, m.Users.Count(), len(users)) {
+		return ErrRefreshHeldBack
+	}
+
+	previous, replaced := m.Users.setAll(users)
+	if replaced && !m.subscribers.empty() {
+		m.subscribers.publish(diffEntities(EntityKindUser, previous, users))
+	}
+
+	m.checkDuplicateSAMAccountNames()
 
 	return nil
 }
 
+// checkDuplicateSAMAccountNames warns about users sharing a sAMAccountName
+// (e.g. across trusted domains), as lookups by it can only return one of them.
+func (m *Manager) checkDuplicateSAMAccountNames() {
+	duplicates := m.Users.Duplicates(func(user ldap.User) string {
+		return user.SAMAccountName
+	})
+
+	names := make([]string, 0, len(duplicates))
+	for name, dns := range duplicates {
+		log.Warn().Msgf("sAMAccountName \"%s\" is used by multiple users: %s", name, strings.Join(dns, "; "))
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	m.refreshM.Lock()
+	m.duplicateSAMAccountNames = names
+	m.refreshM.Unlock()
+}
+
+// DuplicateSAMAccountNames returns the sAMAccountNames used by more than one
+// user as of the last user refresh.
+func (m *Manager) DuplicateSAMAccountNames() []string {
+	m.refreshM.RLock()
+	defer m.refreshM.RUnlock()
+
+	return m.duplicateSAMAccountNames
+}
+
 func (m *Manager) RefreshGroups() error {
-	groups, err := m.client.FindGroups()
+	var groups []ldap.Group
+	err := TimeOperation(m.config.SlowOperationThreshold, "find groups", "", func() (err error) {
+		groups, err = m.client.FindGroups()
+		if err != nil {
+			return err
+		}
+
+		groups, err = filtered(m, m.config.GroupFilter, defaultGroupFilter, groups)
+
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
-	m.Groups.setAll(groups)
+	if !m.acceptRefresh(EntityKindGroup, m.Groups.Count(), len(groups)) {
+		return ErrRefreshHeldBack
+	}
+
+	previous, replaced := m.Groups.setAll(groups)
+	if replaced && !m.subscribers.empty() {
+		m.subscribers.publish(diffEntities(EntityKindGroup, previous, groups))
+	}
 
 	return nil
 }
 
 func (m *Manager) RefreshComputers() error {
-	computers, err := m.client.FindComputers()
+	var computers []ldap.Computer
+	err := TimeOperation(m.config.SlowOperationThreshold, "find computers", "", func() (err error) {
+		computers, err = m.client.FindComputers()
+		if err != nil {
+			return err
+		}
+
+		computers, err = filtered(m, m.config.ComputerFilter, defaultComputerFilter, computers)
+
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
-	m.Computers.setAll(computers)
+	if !m.acceptRefresh(EntityKindComputer, m.Computers.Count(), len(computers)) {
+		return ErrRefreshHeldBack
+	}
+
+	previous, replaced := m.Computers.setAll(computers)
+	if replaced && !m.subscribers.empty() {
+		m.subscribers.publish(diffEntities(EntityKindComputer, previous, computers))
+	}
 
 	return nil
 }
 
-func (m *Manager) Refresh() {
+func (m *Manager) Refresh() error {
+	var errs []error
+
 	if err := m.RefreshUsers(); err != nil {
 		log.Error().Err(err).Send()
+		errs = append(errs, err)
 	}
 
 	if err := m.RefreshGroups(); err != nil {
 		log.Error().Err(err).Send()
+		errs = append(errs, err)
 	}
 
 	if err := m.RefreshComputers(); err != nil {
 		log.Error().Err(err).Send()
+		errs = append(errs, err)
 	}
 
 	log.Debug().Msgf("Refreshed LDAP cache with %d users, %d groups and %d computers", m.Users.Count(), m.Groups.Count(), m.Computers.Count())
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	m.refreshM.Lock()
+	m.lastRefresh = m.config.Clock.Now()
+	m.refreshM.Unlock()
+
+	return nil
+}
+
+// LastRefresh returns the time of the last refresh in which all entity types
+// could be fetched. It is the zero time until the first successful refresh.
+func (m *Manager) LastRefresh() time.Time {
+	m.refreshM.RLock()
+	defer m.refreshM.RUnlock()
+
+	return m.lastRefresh
+}
+
+func (m *Manager) setRefreshFailing(failing bool) {
+	m.refreshM.Lock()
+	defer m.refreshM.Unlock()
+
+	m.refreshFailing = failing
+}
+
+// IsStale reports whether the latest refresh failed, so the cache still holds
+// the data of the last successful one, see LastRefresh.
+func (m *Manager) IsStale() bool {
+	m.refreshM.RLock()
+	defer m.refreshM.RUnlock()
+
+	return m.refreshFailing
+}
+
+// IsWarmedUp reports whether the cache has been filled completely at least once.
+// It stays true even if later refreshes fail.
+func (m *Manager) IsWarmedUp() bool {
+	return !m.LastRefresh().IsZero()
 }
 
 func (m *Manager) FindUsers(showDisabled bool) []ldap.User {
@@ -176,17 +527,46 @@ func (m *Manager) FindComputerByDN(dn string) (*ldap.Computer, error) {
 	return computer, nil
 }
 
+// FindComputerBySAMAccountName finds a computer with or without the trailing
+// "$" ActiveDirectory appends to the sAMAccountName of computer accounts.
+func (m *Manager) FindComputerBySAMAccountName(samAccountName string) (*ldap.Computer, error) {
+	name := ComputerName(samAccountName)
+	computer, found := m.Computers.Find(func(computer ldap.Computer) bool {
+		return ComputerName(computer.SAMAccountName) == name
+	})
+	if !found {
+		return nil, ldap.ErrComputerNotFound
+	}
+
+	return computer, nil
+}
+
+// ComputerName returns the sAMAccountName of a computer without the trailing "$".
+func ComputerName(samAccountName string) string {
+	return strings.TrimSuffix(samAccountName, "$")
+}
+
 func (m *Manager) PopulateGroupsForUser(user *ldap.User) *FullLDAPUser {
 	full := &FullLDAPUser{
-		User:   *user,
-		Groups: make([]ldap.Group, 0),
+		User:    *user,
+		Groups:  make([]ldap.Group, 0),
+		Changes: make([]AttributeChange, 0),
 	}
 
+	if previous, found := m.Users.FindPreviousByDN(user.DN()); found {
+		full.Changes = DiffUsers(*previous, *user)
+	}
+
+	groups := m.Groups.Snapshot()
 	for _, groupDN := range user.Groups {
-		group, err := m.FindGroupByDN(groupDN)
-		if err == nil {
-			full.Groups = append(full.Groups, *group)
+		group, found := groups.FindByDN(groupDN)
+		if !found {
+			full.Unresolved++
+
+			continue
 		}
+
+		full.Groups = append(full.Groups, *group)
 	}
 
 	return full
@@ -198,15 +578,20 @@ func (m *Manager) PopulateUsersForGroup(group *ldap.Group, showDisabled bool) *F
 		Members: make([]ldap.User, 0),
 	}
 
+	users := m.Users.Snapshot()
 	for _, userDN := range group.Members {
-		user, err := m.FindUserByDN(userDN)
-		if err == nil {
-			if showDisabled && !user.Enabled {
-				continue
-			}
+		user, found := users.FindByDN(userDN)
+		if !found {
+			full.Unresolved++
 
-			full.Members = append(full.Members, *user)
+			continue
 		}
+
+		if !showDisabled && !user.Enabled {
+			continue
+		}
+
+		full.Members = append(full.Members, *user)
 	}
 
 	return full
@@ -218,23 +603,33 @@ func (m *Manager) PopulateGroupsForComputer(computer *ldap.Computer) *FullLDAPCo
 		Groups:   make([]ldap.Group, 0),
 	}
 
+	groups := m.Groups.Snapshot()
 	for _, groupDN := range computer.Groups {
-		group, err := m.FindGroupByDN(groupDN)
-		if err == nil {
-			full.Groups = append(full.Groups, *group)
+		group, found := groups.FindByDN(groupDN)
+		if !found {
+			full.Unresolved++
+
+			continue
 		}
+
+		full.Groups = append(full.Groups, *group)
 	}
 
 	return full
 }
 
+// OnAddUserToGroup records a successful modification in the cache until the
+// next refresh. Recording a membership which already exists has no effect.
 func (m *Manager) OnAddUserToGroup(userDN string, groupDN string) {
+	m.membershipM.Lock()
+	defer m.membershipM.Unlock()
+
 	m.Users.update(func(user *ldap.User) {
 		if user.DN() != userDN {
 			return
 		}
 
-		user.Groups = append(user.Groups, groupDN)
+		user.Groups = with(user.Groups, groupDN)
 	})
 
 	m.Groups.update(func(group *ldap.Group) {
@@ -242,21 +637,20 @@ func (m *Manager) OnAddUserToGroup(userDN string, groupDN string) {
 			return
 		}
 
-		group.Members = append(group.Members, userDN)
+		group.Members = with(group.Members, userDN)
 	})
 }
 
 func (m *Manager) OnRemoveUserFromGroup(userDN string, groupDN string) {
+	m.membershipM.Lock()
+	defer m.membershipM.Unlock()
+
 	m.Users.update(func(user *ldap.User) {
 		if user.DN() != userDN {
 			return
 		}
 
-		for idx, group := range user.Groups {
-			if group == groupDN {
-				user.Groups = append(user.Groups[:idx], user.Groups[idx+1:]...)
-			}
-		}
+		user.Groups = without(user.Groups, groupDN)
 	})
 
 	m.Groups.update(func(group *ldap.Group) {
@@ -264,10 +658,63 @@ func (m *Manager) OnRemoveUserFromGroup(userDN string, groupDN string) {
 			return
 		}
 
-		for idx, member := range group.Members {
-			if member == userDN {
-				group.Members = append(group.Members[:idx], group.Members[idx+1:]...)
+		group.Members = without(group.Members, userDN)
+	})
+}
+
+// OnModifyUserAttributes records modified attributes in the cache until the
+// next refresh. Attributes which are not cached are ignored.
+func (m *Manager) OnModifyUserAttributes(userDN string, attributes map[string][]string) {
+	m.Users.update(func(user *ldap.User) {
+		if user.DN() != userDN {
+			return
+		}
+
+		for name, values := range attributes {
+			value := ""
+			if len(values) > 0 {
+				value = values[0]
+			}
+
+			switch strings.ToLower(name) {
+			case "description":
+				user.Description = value
+			case "mail":
+				if value == "" {
+					user.Mail = nil
+				} else {
+					user.Mail = &value
+				}
 			}
 		}
 	})
 }
+
+// with returns a new slice with value appended, unless it is already contained.
+// Appending to the original slice could write into memory shared with
+// snapshots, so it is always copied.
+func with(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+
+	result := make([]string, len(values), len(values)+1)
+	copy(result, values)
+
+	return append(result, value)
+}
+
+// without returns a new slice with all occurrences of value removed. The
+// original slice is left untouched, as snapshots may still refer to it.
+func without(values []string, value string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,218 @@
+package ldap_cache
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/netresearch/ldap-manager/internal/cron"
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+// fakeClock is a Clock standing still at now.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	return realClock{}.NewTimer(d)
+}
+
+func TestNextDelayFollowsSchedule(t *testing.T) {
+	schedule, err := cron.Parse("0 3 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := &fakeClock{now: time.Date(2024, 3, 13, 10, 0, 0, 0, time.UTC)}
+	m := New(nil, Config{Schedule: schedule, Clock: clock})
+
+	ticks := []struct {
+		now  time.Time
+		want time.Duration
+	}{
+		{time.Date(2024, 3, 13, 10, 0, 0, 0, time.UTC), 17 * time.Hour},
+		{time.Date(2024, 3, 14, 3, 0, 0, 0, time.UTC), 24 * time.Hour},
+		{time.Date(2024, 3, 15, 3, 0, 5, 0, time.UTC), 24*time.Hour - 5*time.Second},
+	}
+
+	for _, tick := range ticks {
+		clock.now = tick.now
+		if got := m.nextDelay(time.Hour, false); got != tick.want {
+			t.Errorf("nextDelay() at %s = %s, want %s", tick.now, got, tick.want)
+		}
+	}
+}
+
+func TestNextDelayBacksOffOnFailureDespiteSchedule(t *testing.T) {
+	schedule, err := cron.Parse("0 3 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := New(nil, Config{Schedule: schedule, Clock: &fakeClock{now: time.Date(2024, 3, 13, 10, 0, 0, 0, time.UTC)}})
+
+	if got := m.nextDelay(time.Minute, true); got != 2*time.Minute {
+		t.Errorf("nextDelay() after a failure = %s, want %s", got, 2*time.Minute)
+	}
+}
+
+func TestNextDelayWithoutSchedule(t *testing.T) {
+	m := New(nil, Config{})
+	if got := m.nextDelay(time.Hour, false); got != refreshInterval {
+		t.Errorf("nextDelay() = %s, want %s", got, refreshInterval)
+	}
+
+	staggered := New(nil, Config{Stagger: true})
+	if got := staggered.nextDelay(time.Hour, false); got != refreshInterval/3 {
+		t.Errorf("staggered nextDelay() = %s, want %s", got, refreshInterval/3)
+	}
+}
+
+func TestNextRefreshDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		current time.Duration
+		failed  bool
+		want    time.Duration
+	}{
+		{"success", refreshInterval, false, refreshInterval},
+		{"success after backoff", 8 * time.Minute, false, refreshInterval},
+		{"first failure", refreshInterval, true, 2 * refreshInterval},
+		{"repeated failure", 2 * time.Minute, true, 4 * time.Minute},
+		{"capped", 8 * time.Minute, true, maxRefreshBackoff},
+		{"at the cap", maxRefreshBackoff, true, maxRefreshBackoff},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextRefreshDelay(refreshInterval, tt.current, tt.failed); got != tt.want {
+				t.Errorf("nextRefreshDelay() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// instantClock is a Clock whose timers fire at once. It records the durations
+// of the timers.
+type instantClock struct {
+	waits []time.Duration
+}
+
+func (c *instantClock) Now() time.Time {
+	return time.Now()
+}
+
+func (c *instantClock) NewTimer(d time.Duration) Timer {
+	c.waits = append(c.waits, d)
+
+	fired := make(chan time.Time, 1)
+	fired <- time.Now()
+
+	return instantTimer{fired}
+}
+
+type instantTimer struct {
+	c chan time.Time
+}
+
+func (t instantTimer) C() <-chan time.Time    { return t.c }
+func (instantTimer) Reset(time.Duration) bool { return false }
+func (instantTimer) Stop() bool               { return false }
+
+// stubClient answers the searches for users with the errors in order and with
+// no entities once they are used up.
+type stubClient struct {
+	errs  []error
+	calls int
+}
+
+func (c *stubClient) FindUsers() ([]ldap.User, error) {
+	c.calls++
+	if len(c.errs) > 0 {
+		err := c.errs[0]
+		c.errs = c.errs[1:]
+
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (c *stubClient) FindGroups() ([]ldap.Group, error)       { return nil, nil }
+func (c *stubClient) FindComputers() ([]ldap.Computer, error) { return nil, nil }
+func (c *stubClient) GetConnection() (*goldap.Conn, error) {
+	return nil, errors.New("no connection")
+}
+
+func TestWarmup(t *testing.T) {
+	busy := goldap.NewError(goldap.LDAPResultBusy, errors.New("busy"))
+	unavailable := goldap.NewError(goldap.LDAPResultUnavailable, errors.New("unavailable"))
+	denied := goldap.NewError(goldap.LDAPResultInsufficientAccessRights, errors.New("denied"))
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantWaits []time.Duration
+		failed    bool
+	}{
+		{"success", nil, 1, nil, false},
+		{"busy twice", []error{busy, unavailable}, 3, []time.Duration{warmupBackoff, 2 * warmupBackoff}, false},
+		{"busy until retries are used up", []error{busy, busy, busy, busy, busy}, warmupRetries + 1, []time.Duration{warmupBackoff, 2 * warmupBackoff, 4 * warmupBackoff}, true},
+		{"other error", []error{denied}, 1, nil, true},
+		{"other error after busy", []error{busy, denied}, 2, []time.Duration{warmupBackoff}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &stubClient{errs: tt.errs}
+			clock := &instantClock{}
+			m := New(client, Config{Clock: clock})
+
+			failed, stopped := m.warmup()
+			if failed != tt.failed || stopped {
+				t.Errorf("warmup() = %v, %v, want %v, false", failed, stopped, tt.failed)
+			}
+			if client.calls != tt.wantCalls {
+				t.Errorf("users were searched %d times, want %d", client.calls, tt.wantCalls)
+			}
+			if !slices.Equal(clock.waits, tt.wantWaits) {
+				t.Errorf("waited %v between the attempts, want %v", clock.waits, tt.wantWaits)
+			}
+			if warmedUp := m.IsWarmedUp(); warmedUp == tt.failed {
+				t.Errorf("IsWarmedUp() = %v after warmup", warmedUp)
+			}
+		})
+	}
+}
+
+func TestFindComputerBySAMAccountName(t *testing.T) {
+	server, client := newTestServer(t)
+	server.AddComputer("cn=desktop,ou=computers,dc=example,dc=com", "DESKTOP$")
+
+	m := New(client, Config{})
+	if err := m.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"DESKTOP$", "DESKTOP"} {
+		computer, err := m.FindComputerBySAMAccountName(name)
+		if err != nil {
+			t.Fatalf("FindComputerBySAMAccountName(%q) = %v", name, err)
+		}
+		if computer.SAMAccountName != "DESKTOP$" {
+			t.Errorf("FindComputerBySAMAccountName(%q) found %q", name, computer.SAMAccountName)
+		}
+	}
+
+	if _, err := m.FindComputerBySAMAccountName("LAPTOP"); err == nil {
+		t.Error("found a computer that does not exist")
+	}
+}
@@ -0,0 +1,26 @@
+package ldap_cache
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// TimeOperation runs fn and logs a warning if it took longer than threshold.
+// A threshold of 0 disables the logging. target may be empty for operations
+// not concerning a single entity.
+func TimeOperation(threshold time.Duration, operation, target string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+
+	if took := time.Since(start); threshold > 0 && took > threshold {
+		event := log.Warn().Str("operation", operation).Dur("duration", took).Err(err)
+		if target != "" {
+			event = event.Str("target", target)
+		}
+
+		event.Msg("slow LDAP operation")
+	}
+
+	return err
+}
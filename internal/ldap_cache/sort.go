@@ -0,0 +1,61 @@
+package ldap_cache
+
+import (
+	"sort"
+
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+// SortOrder is the order entities are kept in by the cache.
+type SortOrder string
+
+const (
+	SortByCN SortOrder = "cn"
+	// SortByName sorts users and computers by their sAMAccountName, groups
+	// have none and are sorted by CN instead.
+	SortByName SortOrder = "name"
+	SortByDN   SortOrder = "dn"
+)
+
+func less[T cacheable](order SortOrder, name func(T) string) func(a, b T) bool {
+	switch order {
+	case SortByDN:
+		return func(a, b T) bool { return a.DN() < b.DN() }
+	case SortByName:
+		return func(a, b T) bool { return name(a) < name(b) }
+	default:
+		return func(a, b T) bool { return a.CN() < b.CN() }
+	}
+}
+
+func userLess(order SortOrder) func(a, b ldap.User) bool {
+	return less(order, func(u ldap.User) string { return u.SAMAccountName })
+}
+
+func groupLess(order SortOrder) func(a, b ldap.Group) bool {
+	return less(order, ldap.Group.CN)
+}
+
+func computerLess(order SortOrder) func(a, b ldap.Computer) bool {
+	return less(order, func(c ldap.Computer) string { return c.SAMAccountName })
+}
+
+func sortBy[T any](items []T, less func(a, b T) bool) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return less(items[i], items[j])
+	})
+}
+
+// SortUsers sorts users in place, for lists requested in another order than
+// the one of the cache.
+func SortUsers(users []ldap.User, order SortOrder) {
+	sortBy(users, userLess(order))
+}
+
+func SortGroups(groups []ldap.Group, order SortOrder) {
+	sortBy(groups, groupLess(order))
+}
+
+func SortComputers(computers []ldap.Computer, order SortOrder) {
+	sortBy(computers, computerLess(order))
+}
@@ -0,0 +1,76 @@
+package ldap_cache
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/netresearch/ldap-manager/internal/ldaptest"
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+func TestGetReturnsSortOrder(t *testing.T) {
+	server, client := newTestServer(t)
+
+	// The DN, CN and sAMAccountName of the users each sort differently.
+	const (
+		first  = "cn=u1,ou=users,dc=example,dc=com"
+		second = "cn=u2,ou=users,dc=example,dc=com"
+		third  = "cn=u3,ou=users,dc=example,dc=com"
+	)
+	for _, user := range []struct{ dn, cn, sAMAccountName string }{
+		{first, "Carol", "amy"},
+		{second, "Alice", "zed"},
+		{third, "Bob", "max"},
+	} {
+		server.Add(ldaptest.Entry{DN: user.dn, Attributes: map[string][]string{
+			"objectClass":        {"top", "person", "organizationalPerson", "user"},
+			"cn":                 {user.cn},
+			"sAMAccountName":     {user.sAMAccountName},
+			"userAccountControl": {"512"},
+		}})
+	}
+
+	found, err := client.FindUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found = slices.DeleteFunc(found, func(user ldap.User) bool { return user.DN() == testReaderDN })
+	// The users are passed to setAll in neither of the orders.
+	byDN := make(map[string]ldap.User, len(found))
+	for _, user := range found {
+		byDN[user.DN()] = user
+	}
+	unsorted := []string{third, first, second}
+
+	tests := []struct {
+		name   string
+		config Config
+		want   []string
+	}{
+		{"default", Config{}, []string{second, third, first}},
+		{"cn", Config{SortOrder: SortByCN}, []string{second, third, first}},
+		{"name", Config{SortOrder: SortByName}, []string{first, third, second}},
+		{"dn", Config{SortOrder: SortByDN}, []string{first, second, third}},
+		{"sorted by the server", Config{SortOrder: SortByDN, Controls: []SearchControl{ControlServerSideSort}}, unsorted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := make([]ldap.User, 0, len(unsorted))
+			for _, dn := range unsorted {
+				users = append(users, byDN[dn])
+			}
+
+			m := New(client, tt.config)
+			m.Users.setAll(users)
+
+			got := make([]string, 0, len(users))
+			for _, user := range m.Users.Get() {
+				got = append(got, user.DN())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Get() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,764 @@
+// Package ldaptest runs an in-memory LDAP server for tests. It answers the
+// binds, searches and modifications sent by simple-ldap-go and go-ldap the way
+// ActiveDirectory does, as far as this application relies on it: memberOf is
+// derived from the member attribute of groups, and locked accounts are
+// reported as invalid credentials with the sub-code 775.
+package ldaptest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+// Diagnostic messages sent by ActiveDirectory for failed binds.
+const (
+	InvalidCredentialsMessage = "80090308: LdapErr: DSID-0C09044E, comment: AcceptSecurityContext error, data 52e, v4563"
+	AccountLockedMessage      = "80090308: LdapErr: DSID-0C09044E, comment: AcceptSecurityContext error, data 775, v4563"
+)
+
+// Entry is an object in the directory. Attribute names are matched case
+// insensitively.
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Request is an operation received by the server.
+type Request struct {
+	// Operation is one of "bind", "search" or "modify".
+	Operation string
+	// DN is the bind DN, the search base or the modified entry.
+	DN string
+	// Filter is the search filter.
+	Filter string
+	// Attributes are the attributes requested by a search.
+	Attributes []string
+	// Controls holds the OIDs of the controls sent with the request.
+	Controls []string
+}
+
+// Result is returned by an interceptor instead of processing a request.
+type Result struct {
+	Code    uint16
+	Message string
+}
+
+// Server is an LDAP server listening on a random local port.
+type Server struct {
+	// URL is the ldap:// URL of the server.
+	URL string
+	// BaseDN is the base of all entries.
+	BaseDN string
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	m         sync.Mutex
+	entries   []*Entry
+	passwords map[string]string
+	locked    map[string]bool
+	requests  []Request
+	intercept func(Request) *Result
+	conns     map[net.Conn]bool
+}
+
+// NewServer starts a server with the given base DN, which is stopped when
+// the test ends.
+func NewServer(t testing.TB, baseDN string) *Server {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not start LDAP server: %v", err)
+	}
+
+	s := &Server{
+		URL:       "ldap://" + listener.Addr().String(),
+		BaseDN:    baseDN,
+		listener:  listener,
+		passwords: make(map[string]string),
+		locked:    make(map[string]bool),
+		conns:     make(map[net.Conn]bool),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Close stops the server and closes all open connections.
+func (s *Server) Close() {
+	_ = s.listener.Close()
+
+	s.m.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.m.Unlock()
+
+	s.wg.Wait()
+}
+
+// Add adds entries to the directory.
+func (s *Server) Add(entries ...Entry) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for _, e := range entries {
+		attributes := make(map[string][]string, len(e.Attributes))
+		for name, values := range e.Attributes {
+			attributes[name] = append([]string(nil), values...)
+		}
+
+		s.entries = append(s.entries, &Entry{DN: e.DN, Attributes: attributes})
+	}
+}
+
+// AddUser adds an enabled user which can bind with password. Its groups are
+// set by the member attribute of the groups.
+func (s *Server) AddUser(dn, sAMAccountName, password string) {
+	s.Add(Entry{DN: dn, Attributes: map[string][]string{
+		"objectClass":        {"top", "person", "organizationalPerson", "user"},
+		"cn":                 {rdnValue(dn)},
+		"sAMAccountName":     {sAMAccountName},
+		"userAccountControl": {"512"},
+	}})
+	s.SetPassword(dn, password)
+}
+
+// AddGroup adds a group with the given members.
+func (s *Server) AddGroup(dn string, members ...string) {
+	s.Add(Entry{DN: dn, Attributes: map[string][]string{
+		"objectClass": {"top", "group"},
+		"cn":          {rdnValue(dn)},
+		"member":      members,
+	}})
+}
+
+// AddComputer adds an enabled computer. ActiveDirectory stores the
+// sAMAccountName of computers with a trailing $.
+func (s *Server) AddComputer(dn, sAMAccountName string) {
+	s.Add(Entry{DN: dn, Attributes: map[string][]string{
+		"objectClass":        {"top", "person", "organizationalPerson", "user", "computer"},
+		"cn":                 {rdnValue(dn)},
+		"sAMAccountName":     {sAMAccountName},
+		"userAccountControl": {"4096"},
+	}})
+}
+
+// SetPassword sets the password dn can bind with.
+func (s *Server) SetPassword(dn, password string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.passwords[strings.ToLower(dn)] = password
+}
+
+// Lock makes binds of dn fail like for an account locked by ActiveDirectory.
+func (s *Server) Lock(dn string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.locked[strings.ToLower(dn)] = true
+}
+
+// Intercept calls fn for every request before it is processed. If fn
+// returns a result, it is sent instead. fn may block to simulate a slow server.
+func (s *Server) Intercept(fn func(Request) *Result) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.intercept = fn
+}
+
+// Requests returns all requests received so far.
+func (s *Server) Requests() []Request {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// Count returns how many requests of the operation were received.
+func (s *Server) Count(operation string) int {
+	count := 0
+	for _, req := range s.Requests() {
+		if req.Operation == operation {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Values returns the values of an attribute of the entry with the DN.
+func (s *Server) Values(dn, attribute string) []string {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	e := s.find(dn)
+	if e == nil {
+		return nil
+	}
+
+	return append([]string(nil), e.values(attribute)...)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.m.Lock()
+		s.conns[conn] = true
+		s.m.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.m.Lock()
+				delete(s.conns, conn)
+				s.m.Unlock()
+				_ = conn.Close()
+			}()
+
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	// Requests are answered one after another, which is all the clients
+	// used by the application need.
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		id := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		var controls []*ber.Packet
+		if len(packet.Children) > 2 {
+			controls = packet.Children[2].Children
+		}
+
+		var responses []*ber.Packet
+		switch op.Tag {
+		case goldap.ApplicationBindRequest:
+			responses = s.bind(op)
+		case goldap.ApplicationSearchRequest:
+			responses = s.search(op, controls)
+		case goldap.ApplicationModifyRequest:
+			responses = s.modify(op)
+		case goldap.ApplicationUnbindRequest:
+			return
+		default:
+			responses = []*ber.Packet{result(op.Tag+1, goldap.LDAPResultUnwillingToPerform, "operation not supported")}
+		}
+
+		for _, response := range responses {
+			envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+			envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "Message ID"))
+			envelope.AppendChild(response)
+
+			if _, err := conn.Write(envelope.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// receive records a request and runs the interceptor.
+func (s *Server) receive(req Request) *Result {
+	s.m.Lock()
+	s.requests = append(s.requests, req)
+	intercept := s.intercept
+	s.m.Unlock()
+
+	if intercept == nil {
+		return nil
+	}
+
+	return intercept(req)
+}
+
+func result(tag ber.Tag, code uint16, message string) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message, "Diagnostic Message"))
+
+	return p
+}
+
+func (s *Server) bind(op *ber.Packet) []*ber.Packet {
+	dn := string(op.Children[1].ByteValue)
+	// Context specific values, like the password or parts of filters, are
+	// only decoded into Data, not into ByteValue.
+	password := op.Children[2].Data.String()
+
+	if r := s.receive(Request{Operation: "bind", DN: dn}); r != nil {
+		return []*ber.Packet{result(goldap.ApplicationBindResponse, r.Code, r.Message)}
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	// An empty password is an unauthenticated bind, which succeeds for any
+	// DN like on servers not rejecting them.
+	if password == "" {
+		return []*ber.Packet{result(goldap.ApplicationBindResponse, goldap.LDAPResultSuccess, "")}
+	}
+
+	expected, exists := s.passwords[strings.ToLower(dn)]
+	if !exists || expected != password {
+		return []*ber.Packet{result(goldap.ApplicationBindResponse, goldap.LDAPResultInvalidCredentials, InvalidCredentialsMessage)}
+	}
+
+	if s.locked[strings.ToLower(dn)] {
+		return []*ber.Packet{result(goldap.ApplicationBindResponse, goldap.LDAPResultInvalidCredentials, AccountLockedMessage)}
+	}
+
+	return []*ber.Packet{result(goldap.ApplicationBindResponse, goldap.LDAPResultSuccess, "")}
+}
+
+func (s *Server) search(op *ber.Packet, controls []*ber.Packet) []*ber.Packet {
+	base := string(op.Children[0].ByteValue)
+	scope := op.Children[1].Value.(int64)
+	filter := op.Children[6]
+
+	filterString, err := goldap.DecompileFilter(filter)
+	if err != nil {
+		return []*ber.Packet{result(goldap.ApplicationSearchResultDone, goldap.LDAPResultProtocolError, err.Error())}
+	}
+
+	attributes := make([]string, 0, len(op.Children[7].Children))
+	for _, attribute := range op.Children[7].Children {
+		attributes = append(attributes, string(attribute.ByteValue))
+	}
+
+	req := Request{Operation: "search", DN: base, Filter: filterString, Attributes: attributes}
+	var sortBy string
+	var reverse bool
+	for _, control := range controls {
+		if len(control.Children) == 0 {
+			continue
+		}
+
+		oid := string(control.Children[0].ByteValue)
+		req.Controls = append(req.Controls, oid)
+		if oid == goldap.ControlTypeServerSideSorting {
+			sortBy, reverse = sortKey(control)
+		}
+	}
+	if r := s.receive(req); r != nil {
+		return []*ber.Packet{result(goldap.ApplicationSearchResultDone, r.Code, r.Message)}
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	found := make([]*Entry, 0)
+	for _, e := range s.entries {
+		if !inScope(e.DN, base, scope) {
+			continue
+		}
+
+		matches, err := s.matches(e, filter)
+		if err != nil {
+			return []*ber.Packet{result(goldap.ApplicationSearchResultDone, goldap.LDAPResultProtocolError, err.Error())}
+		}
+		if matches {
+			found = append(found, e)
+		}
+	}
+
+	if sortBy != "" {
+		s.sort(found, sortBy, reverse)
+	}
+
+	responses := make([]*ber.Packet, 0, len(found)+1)
+	for _, e := range found {
+		responses = append(responses, s.searchEntry(e, attributes))
+	}
+
+	if len(responses) == 0 && scope == goldap.ScopeBaseObject && s.find(base) == nil {
+		return []*ber.Packet{result(goldap.ApplicationSearchResultDone, goldap.LDAPResultNoSuchObject, "no such object")}
+	}
+
+	return append(responses, result(goldap.ApplicationSearchResultDone, goldap.LDAPResultSuccess, ""))
+}
+
+// sortKey returns the first sort key of a server-side sorting control
+// (RFC 2891).
+func sortKey(control *ber.Packet) (attribute string, reverse bool) {
+	for _, child := range control.Children[1:] {
+		if child.Tag != ber.TagOctetString {
+			continue
+		}
+
+		keys, err := ber.DecodePacketErr(child.ByteValue)
+		if err != nil || len(keys.Children) == 0 || len(keys.Children[0].Children) == 0 {
+			return "", false
+		}
+
+		key := keys.Children[0]
+		attribute = string(key.Children[0].ByteValue)
+		for _, option := range key.Children[1:] {
+			if option.ClassType == ber.ClassContext && option.Tag == 1 {
+				reverse = len(option.Data.Bytes()) > 0 && option.Data.Bytes()[0] != 0
+			}
+		}
+
+		return attribute, reverse
+	}
+
+	return "", false
+}
+
+// sort orders entries by the first value of attribute, case insensitively.
+// Entries without the attribute come last.
+func (s *Server) sort(entries []*Entry, attribute string, reverse bool) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := s.values(entries[i], attribute), s.values(entries[j], attribute)
+		if len(a) == 0 || len(b) == 0 {
+			return len(a) > len(b)
+		}
+
+		if reverse {
+			return strings.ToLower(a[0]) > strings.ToLower(b[0])
+		}
+
+		return strings.ToLower(a[0]) < strings.ToLower(b[0])
+	})
+}
+
+func (s *Server) searchEntry(e *Entry, requested []string) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, goldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.DN, "DN"))
+
+	names := requested
+	all := len(requested) == 0
+	for _, name := range requested {
+		if name == "*" {
+			all = true
+		}
+	}
+	if all {
+		names = make([]string, 0, len(e.Attributes)+1)
+		for name := range e.Attributes {
+			names = append(names, name)
+		}
+		names = append(names, "memberOf")
+	}
+
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for _, name := range names {
+		values := s.values(e, name)
+		if len(values) == 0 {
+			continue
+		}
+
+		// Attributes are returned with the name as requested, since go-ldap
+		// looks them up case sensitively.
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	p.AppendChild(attributes)
+
+	return p
+}
+
+func (s *Server) modify(op *ber.Packet) []*ber.Packet {
+	dn := string(op.Children[0].ByteValue)
+
+	if r := s.receive(Request{Operation: "modify", DN: dn}); r != nil {
+		return []*ber.Packet{result(goldap.ApplicationModifyResponse, r.Code, r.Message)}
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	e := s.find(dn)
+	if e == nil {
+		return []*ber.Packet{result(goldap.ApplicationModifyResponse, goldap.LDAPResultNoSuchObject, "no such object")}
+	}
+
+	// Changes are applied to a copy, so a failing change leaves the entry
+	// untouched.
+	attributes := make(map[string][]string, len(e.Attributes))
+	for name, values := range e.Attributes {
+		attributes[name] = values
+	}
+	changed := &Entry{DN: e.DN, Attributes: attributes}
+
+	for _, change := range op.Children[1].Children {
+		operation := change.Children[0].Value.(int64)
+		name := string(change.Children[1].Children[0].ByteValue)
+		values := make([]string, 0)
+		for _, value := range change.Children[1].Children[1].Children {
+			values = append(values, string(value.ByteValue))
+		}
+
+		if code, message := changed.apply(operation, name, values); code != goldap.LDAPResultSuccess {
+			return []*ber.Packet{result(goldap.ApplicationModifyResponse, code, message)}
+		}
+	}
+
+	e.Attributes = changed.Attributes
+
+	return []*ber.Packet{result(goldap.ApplicationModifyResponse, goldap.LDAPResultSuccess, "")}
+}
+
+func (e *Entry) apply(operation int64, name string, values []string) (uint16, string) {
+	key := e.key(name)
+	current := e.Attributes[key]
+
+	switch operation {
+	case goldap.AddAttribute:
+		for _, value := range values {
+			if containsFold(current, value) {
+				return goldap.LDAPResultAttributeOrValueExists, fmt.Sprintf("%s already contains %s", name, value)
+			}
+			current = append(current, value)
+		}
+	case goldap.DeleteAttribute:
+		if len(values) == 0 {
+			current = nil
+
+			break
+		}
+
+		for _, value := range values {
+			if !containsFold(current, value) {
+				return goldap.LDAPResultNoSuchAttribute, fmt.Sprintf("%s does not contain %s", name, value)
+			}
+			current = withoutFold(current, value)
+		}
+	case goldap.ReplaceAttribute:
+		current = values
+	default:
+		return goldap.LDAPResultProtocolError, "unknown modify operation"
+	}
+
+	attributes := make(map[string][]string, len(e.Attributes))
+	for k, v := range e.Attributes {
+		attributes[k] = v
+	}
+	if len(current) == 0 {
+		delete(attributes, key)
+	} else {
+		attributes[key] = append([]string(nil), current...)
+	}
+	e.Attributes = attributes
+
+	return goldap.LDAPResultSuccess, ""
+}
+
+// key returns the stored name of the attribute, or name if it is not set.
+func (e *Entry) key(name string) string {
+	for key := range e.Attributes {
+		if strings.EqualFold(key, name) {
+			return key
+		}
+	}
+
+	return name
+}
+
+func (e *Entry) values(name string) []string {
+	return e.Attributes[e.key(name)]
+}
+
+// values returns the values of an attribute, deriving memberOf from the
+// groups like ActiveDirectory.
+func (s *Server) values(e *Entry, name string) []string {
+	if !strings.EqualFold(name, "memberOf") {
+		return e.values(name)
+	}
+
+	groups := make([]string, 0)
+	for _, group := range s.entries {
+		if containsFold(group.values("member"), e.DN) {
+			groups = append(groups, group.DN)
+		}
+	}
+
+	return groups
+}
+
+func (s *Server) find(dn string) *Entry {
+	for _, e := range s.entries {
+		if strings.EqualFold(e.DN, dn) {
+			return e
+		}
+	}
+
+	return nil
+}
+
+func inScope(dn, base string, scope int64) bool {
+	dn, base = strings.ToLower(dn), strings.ToLower(base)
+
+	switch scope {
+	case goldap.ScopeBaseObject:
+		return dn == base
+	case goldap.ScopeSingleLevel:
+		_, parent, found := strings.Cut(dn, ",")
+		return found && parent == base
+	default:
+		return dn == base || base == "" || strings.HasSuffix(dn, ","+base)
+	}
+}
+
+// matchingRuleBitAnd is the ActiveDirectory matching rule testing whether
+// all bits of the value are set, e.g. in userAccountControl.
+const matchingRuleBitAnd = "1.2.840.113556.1.4.803"
+
+func (s *Server) matches(e *Entry, filter *ber.Packet) (bool, error) {
+	switch filter.Tag {
+	case goldap.FilterAnd:
+		for _, child := range filter.Children {
+			if ok, err := s.matches(e, child); err != nil || !ok {
+				return false, err
+			}
+		}
+
+		return true, nil
+	case goldap.FilterOr:
+		for _, child := range filter.Children {
+			if ok, err := s.matches(e, child); err != nil || ok {
+				return ok, err
+			}
+		}
+
+		return false, nil
+	case goldap.FilterNot:
+		ok, err := s.matches(e, filter.Children[0])
+
+		return !ok, err
+	case goldap.FilterEqualityMatch, goldap.FilterApproxMatch:
+		name := string(filter.Children[0].ByteValue)
+		return containsFold(s.values(e, name), string(filter.Children[1].ByteValue)), nil
+	case goldap.FilterPresent:
+		return len(s.values(e, filter.Data.String())) > 0, nil
+	case goldap.FilterSubstrings:
+		name := string(filter.Children[0].ByteValue)
+		for _, value := range s.values(e, name) {
+			if matchSubstrings(strings.ToLower(value), filter.Children[1].Children) {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	case goldap.FilterExtensibleMatch:
+		var rule, name, value string
+		for _, child := range filter.Children {
+			switch child.Tag {
+			case goldap.MatchingRuleAssertionMatchingRule:
+				rule = child.Data.String()
+			case goldap.MatchingRuleAssertionType:
+				name = child.Data.String()
+			case goldap.MatchingRuleAssertionMatchValue:
+				value = child.Data.String()
+			}
+		}
+		if rule != matchingRuleBitAnd {
+			return false, fmt.Errorf("matching rule %s is not supported", rule)
+		}
+
+		var bits uint64
+		if _, err := fmt.Sscan(value, &bits); err != nil {
+			return false, err
+		}
+		for _, v := range s.values(e, name) {
+			var flags uint64
+			if _, err := fmt.Sscan(v, &flags); err == nil && flags&bits == bits {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	default:
+		return false, errors.New("filter type is not supported")
+	}
+}
+
+func matchSubstrings(value string, parts []*ber.Packet) bool {
+	for idx, part := range parts {
+		sub := strings.ToLower(part.Data.String())
+
+		switch part.Tag {
+		case goldap.FilterSubstringsInitial:
+			if !strings.HasPrefix(value, sub) {
+				return false
+			}
+			value = value[len(sub):]
+		case goldap.FilterSubstringsAny:
+			i := strings.Index(value, sub)
+			if i < 0 {
+				return false
+			}
+			value = value[i+len(sub):]
+		case goldap.FilterSubstringsFinal:
+			if idx != len(parts)-1 || !strings.HasSuffix(value, sub) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func withoutFold(values []string, value string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if !strings.EqualFold(v, value) {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
+// rdnValue returns the value of the first RDN of dn, e.g. "John Doe" for
+// "cn=John Doe,ou=users,dc=example,dc=com".
+func rdnValue(dn string) string {
+	rdn, _, _ := strings.Cut(dn, ",")
+	_, value, _ := strings.Cut(rdn, "=")
+
+	return value
+}
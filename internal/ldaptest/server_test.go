@@ -0,0 +1,132 @@
+package ldaptest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	goldap "github.com/go-ldap/ldap/v3"
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+const (
+	baseDN    = "dc=example,dc=com"
+	readerDN  = "cn=reader,dc=example,dc=com"
+	jdoeDN    = "cn=John Doe,ou=users,dc=example,dc=com"
+	adminsDN  = "cn=admins,ou=groups,dc=example,dc=com"
+	desktopDN = "cn=desktop,ou=computers,dc=example,dc=com"
+)
+
+func newServer(t *testing.T) (*Server, *ldap.LDAP) {
+	t.Helper()
+
+	s := NewServer(t, baseDN)
+	s.AddUser(readerDN, "reader", "secret")
+	s.AddUser(jdoeDN, "jdoe", "hunter2")
+	s.AddGroup(adminsDN, jdoeDN)
+	s.AddComputer(desktopDN, "DESKTOP$")
+
+	client, err := ldap.New(ldap.Config{Server: s.URL, BaseDN: baseDN, IsActiveDirectory: true}, readerDN, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, client
+}
+
+func TestSearch(t *testing.T) {
+	_, client := newServer(t)
+
+	users, err := client.FindUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Computers are users as well in ActiveDirectory.
+	if len(users) != 3 {
+		t.Fatalf("FindUsers() returned %d users, want 3", len(users))
+	}
+
+	user, err := client.FindUserBySAMAccountName("jdoe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.DN() != jdoeDN || len(user.Groups) != 1 || user.Groups[0] != adminsDN || !user.Enabled {
+		t.Errorf("FindUserBySAMAccountName() = %+v", user)
+	}
+
+	computers, err := client.FindComputers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(computers) != 1 || computers[0].SAMAccountName != "DESKTOP$" {
+		t.Errorf("FindComputers() = %+v", computers)
+	}
+}
+
+func TestBind(t *testing.T) {
+	s, client := newServer(t)
+
+	if _, err := client.CheckPasswordForSAMAccountName("jdoe", "hunter2"); err != nil {
+		t.Errorf("valid credentials: %v", err)
+	}
+
+	_, err := client.CheckPasswordForSAMAccountName("jdoe", "wrong")
+	if !goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) || !strings.Contains(err.Error(), "data 52e") {
+		t.Errorf("invalid credentials: %v", err)
+	}
+
+	s.Lock(jdoeDN)
+	_, err = client.CheckPasswordForSAMAccountName("jdoe", "hunter2")
+	if !goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) || !strings.Contains(err.Error(), "data 775") {
+		t.Errorf("locked account: %v", err)
+	}
+}
+
+func TestModify(t *testing.T) {
+	s, client := newServer(t)
+
+	if err := client.RemoveUserFromGroup(jdoeDN, adminsDN); err != nil {
+		t.Fatal(err)
+	}
+	if members := s.Values(adminsDN, "member"); len(members) != 0 {
+		t.Errorf("members after removal = %v", members)
+	}
+
+	err := client.RemoveUserFromGroup(jdoeDN, adminsDN)
+	if !goldap.IsErrorWithCode(err, goldap.LDAPResultNoSuchAttribute) {
+		t.Errorf("removing a missing member: %v", err)
+	}
+
+	if err := client.AddUserToGroup(jdoeDN, adminsDN); err != nil {
+		t.Fatal(err)
+	}
+	if members := s.Values(adminsDN, "member"); len(members) != 1 || members[0] != jdoeDN {
+		t.Errorf("members after adding = %v", members)
+	}
+}
+
+func TestIntercept(t *testing.T) {
+	s, client := newServer(t)
+
+	s.Intercept(func(req Request) *Result {
+		if req.Operation == "search" {
+			return &Result{Code: goldap.LDAPResultBusy, Message: "busy"}
+		}
+
+		return nil
+	})
+
+	_, err := client.FindGroups()
+
+	var ldapErr *goldap.Error
+	if !errors.As(err, &ldapErr) || ldapErr.ResultCode != goldap.LDAPResultBusy {
+		t.Errorf("FindGroups() error = %v, want busy", err)
+	}
+
+	if got := s.Count("search"); got != 1 {
+		t.Errorf("Count(search) = %d, want 1", got)
+	}
+	if got := s.Requests()[len(s.Requests())-1].Filter; got != "(objectClass=group)" {
+		t.Errorf("recorded filter = %s", got)
+	}
+}
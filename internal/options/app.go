@@ -3,26 +3,211 @@ package options
 import (
 	"flag"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/joho/godotenv"
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/netresearch/ldap-manager/internal/cron"
+	"github.com/netresearch/ldap-manager/internal/ldap_cache"
 	ldap "github.com/netresearch/simple-ldap-go"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
+type LogFormat string
+
+const (
+	LogFormatConsole LogFormat = "console"
+	LogFormatJSON    LogFormat = "json"
+)
+
+// EarlyLogFormat returns the log format given by --log-format or LOG_FORMAT,
+// so the logger can be set up before Parse logs anything. A format only set in
+// an env file is not known yet, it applies once Parse loaded the file.
+// Invalid values fall back to the console format and are rejected by Parse.
+func EarlyLogFormat() LogFormat {
+	raw, ok := flagArg(os.Args[1:], "log-format")
+	if !ok {
+		raw = os.Getenv("LOG_FORMAT")
+	}
+
+	if LogFormat(raw) == LogFormatJSON {
+		return LogFormatJSON
+	}
+
+	return LogFormatConsole
+}
+
+type Mode string
+
+const (
+	ModeFull    Mode = "full"
+	ModeMonitor Mode = "monitor"
+)
+
+type DNValidation string
+
+const (
+	DNValidationWarn   DNValidation = "warn"
+	DNValidationReject DNValidation = "reject"
+)
+
+type Landing string
+
+const (
+	LandingProfile   Landing = "profile"
+	LandingUsers     Landing = "users"
+	LandingGroups    Landing = "groups"
+	LandingComputers Landing = "computers"
+	LandingDashboard Landing = "dashboard"
+)
+
+type CookieSecure string
+
+const (
+	CookieSecureAuto   CookieSecure = "auto"
+	CookieSecureAlways CookieSecure = "true"
+	CookieSecureNever  CookieSecure = "false"
+)
+
 type Opts struct {
-	LogLevel zerolog.Level
+	Mode Mode
+
+	LogLevel         zerolog.Level
+	LogFormat        LogFormat
+	LogSampleRate    uint32
+	LogConfigSummary bool
+
+	AppTitle   string
+	AppLogoURL string
 
-	LDAP             ldap.Config
-	ReadonlyUser     string
-	ReadonlyPassword string
+	LDAP                   ldap.Config
+	AllowInsecureBind      bool
+	ReadonlyUser           string
+	ReadonlyPassword       string
+	LDAPUserFilter         string
+	LDAPGroupFilter        string
+	LDAPComputerFilter     string
+	LDAPSearchControls     []ldap_cache.SearchControl
+	LDAPMaxConcurrentBinds int
+	LDAPBindWaitWarning    time.Duration
+	SlowQueryThreshold     time.Duration
+	SelfTestInterval       time.Duration
+	SelfTestBaseDN         string
+	SelfTestFilter         string
+	SelfTestScope          int
+	CacheRefreshSchedule   *cron.Schedule
+	CacheRefreshStagger    bool
+	CacheSort              ldap_cache.SortOrder
+	CacheMaxDropPercent    float64
+	CacheIndexShards       int
+	StaleServeMaxAge       time.Duration
+
+	PersistSessions        bool
+	SessionPath            string
+	SessionDuration        time.Duration
+	SessionCleanupInterval time.Duration
+	CookieSecure           CookieSecure
+	TrustedProxies         []string
+	LogoutOnInvalidCreds   bool
+	LoginMaxFailures       int
+	LoginFailureWindow     time.Duration
+	AuthVerifyEnabled      bool
+
+	RequestLogEnabled     bool
+	RequestTimeout        time.Duration
+	ShutdownTimeout       time.Duration
+	MaxConcurrentRequests int
+	MaxDNLength           int
+	MaxDNDepth            int
+	CSPPolicy             string
+	CompressionMinSize    int
+
+	DefaultLanding       Landing
+	ShowDisabledDefault  bool
+	HTMXPartials         bool
+	CacheAgeHeaderHTML   bool
+	EditableAttributes   []string
+	LDIFExportAttributes []string
+	BulkMaxUsers         int
+	BulkConcurrency      int
+	DNValidation         DNValidation
+
+	WebhookURL    string
+	WebhookSecret string
+}
+
+// attributeNamePattern matches LDAP attribute type names (RFC 4512 descr).
+var attributeNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*$`)
+
+// parseAttributes parses a comma separated list of attribute names given to
+// the option with the given name.
+func parseAttributes(option, value string) []string {
+	attributes := make([]string, 0)
+	for _, attribute := range strings.Split(value, ",") {
+		attribute = strings.TrimSpace(attribute)
+		if attribute == "" {
+			continue
+		}
+
+		if !attributeNamePattern.MatchString(attribute) {
+			log.Fatal().Msgf("the option --%s contains the invalid attribute name \"%s\"", option, attribute)
+		}
+
+		attributes = append(attributes, attribute)
+	}
+
+	return attributes
+}
+
+// parseFilter validates the LDAP filter given to the option with the given
+// name. An empty filter is kept as is.
+func parseFilter(option, value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+
+	if _, err := goldap.CompileFilter(value); err != nil {
+		log.Fatal().Err(err).Msgf("the option --%s has to be a valid LDAP filter, got \"%s\"", option, value)
+	}
+
+	return value
+}
+
+// parseSearchControls parses the comma separated names of LDAP controls given
+// to --ldap-search-controls.
+func parseSearchControls(value string) []ldap_cache.SearchControl {
+	controls := make([]ldap_cache.SearchControl, 0)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		control := ldap_cache.SearchControl(name)
+		if !slices.Contains(ldap_cache.SearchControls, control) {
+			log.Fatal().Msgf("the option --ldap-search-controls contains the unknown control \"%s\", valid values are: server-side-sort", name)
+		}
+
+		if !slices.Contains(controls, control) {
+			controls = append(controls, control)
+		}
+	}
 
-	PersistSessions bool
-	SessionPath     string
-	SessionDuration time.Duration
+	return controls
+}
+
+var selfTestScopes = map[string]int{
+	"base": goldap.ScopeBaseObject,
+	"one":  goldap.ScopeSingleLevel,
+	"sub":  goldap.ScopeWholeSubtree,
 }
 
 func panicWhenEmpty(name string, value *string) {
@@ -32,6 +217,8 @@ func panicWhenEmpty(name string, value *string) {
 }
 
 func envStringOrDefault(name, d string) string {
+	envNames[name] = true
+
 	if v, exists := os.LookupEnv(name); exists && v != "" {
 		return v
 	}
@@ -50,6 +237,17 @@ func envDurationOrDefault(name string, d time.Duration) time.Duration {
 	return v
 }
 
+func envIntOrDefault(name string, d int) int {
+	raw := envStringOrDefault(name, fmt.Sprintf("%v", d))
+
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Fatal().Msgf("could not parse environment variable \"%s\" (containing \"%s\") as int: %v", name, raw, err)
+	}
+
+	return v
+}
+
 func envLogLevelOrDefault(name string, d zerolog.Level) string {
 	raw := envStringOrDefault(name, d.String())
 
@@ -60,6 +258,17 @@ func envLogLevelOrDefault(name string, d zerolog.Level) string {
 	return raw
 }
 
+func envFloatOrDefault(name string, d float64) float64 {
+	raw := envStringOrDefault(name, fmt.Sprintf("%v", d))
+
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		log.Fatal().Msgf("could not parse environment variable \"%s\" (containing \"%s\") as float: %v", name, raw, err)
+	}
+
+	return v
+}
+
 func envBoolOrDefault(name string, d bool) bool {
 	raw := envStringOrDefault(name, fmt.Sprintf("%v", d))
 
@@ -71,58 +280,422 @@ func envBoolOrDefault(name string, d bool) bool {
 	return v2
 }
 
+func isSafeLogoURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+
+	if u.Scheme == "" && u.Host == "" {
+		return strings.HasPrefix(u.Path, "/")
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// withPort replaces the port of an LDAP server URI, keeping scheme and host.
+func withPort(server string, port int) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("no host in \"%s\"", server)
+	}
+
+	if p := u.Port(); p != "" && p != strconv.Itoa(port) {
+		log.Warn().Msgf("the port %s in --ldap-server is overridden by --ldap-port %d", p, port)
+	}
+
+	u.Host = net.JoinHostPort(u.Hostname(), strconv.Itoa(port))
+
+	return u.String(), nil
+}
+
 func Parse() *Opts {
-	if err := godotenv.Load(".env.local", ".env"); err != nil {
-		log.Warn().Err(err).Msg("could not load .env file")
+	files, explicit := envFiles()
+	loadEnvFiles(files, explicit, requireEnvFile())
+
+	// The config file is loaded after the env files, so it only takes
+	// precedence over the defaults.
+	var configKeys []string
+	config := configFile()
+	if config != "" {
+		configKeys = loadConfigFile(config)
 	}
 
+	// A flag set per call keeps Parse free of global state, so it can be
+	// called more than once.
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+
 	var (
-		fLogLevel = flag.String("log-level", envLogLevelOrDefault("LOG_LEVEL", zerolog.InfoLevel), "Log level. Valid values are: trace, debug, info, warn, error, fatal, panic.")
+		// --env-file is read before parsing by envFiles, it is only defined
+		// here to be accepted and listed by --help.
+		_ = fs.String("env-file", strings.Join(defaultEnvFiles, ","), "Comma separated dotenv files to load, earlier files take precedence. Can also be set with ENV_FILE.")
+		// --config is read before parsing by configFile as well.
+		_ = fs.String("config", "", "TOML file setting options by the names of their environment variables, e.g. LDAP_SERVER = \"ldaps://dc.example.com\". Flags, the environment and the env files take precedence. Can also be set with CONFIG_FILE.")
+
+		fMode = fs.String("mode", envStringOrDefault("MODE", string(ModeFull)), "Mode to run in. Valid values are: full, monitor (only serves the /health and /version endpoints, without the UI and the LDAP cache).")
+
+		fLogLevel         = fs.String("log-level", envLogLevelOrDefault("LOG_LEVEL", zerolog.InfoLevel), "Log level. Valid values are: trace, debug, info, warn, error, fatal, panic.")
+		fLogFormat        = fs.String("log-format", envStringOrDefault("LOG_FORMAT", string(LogFormatConsole)), "Log format. Valid values are: console, json.")
+		fLogSampleRate    = fs.Int("log-sample-rate", envIntOrDefault("LOG_SAMPLE_RATE", 1), "Only log every n-th entry of log sites hit on every request, currently the request log of successful requests. Errors are never sampled.")
+		fLogConfigSummary = fs.Bool("log-config-summary", envBoolOrDefault("LOG_CONFIG_SUMMARY", false), "Whether the effective configuration is logged at startup, with secrets redacted.")
+
+		fAppTitle   = fs.String("app-title", envStringOrDefault("APP_TITLE", "LDAP Manager"), "Title shown in the header and the page titles.")
+		fAppLogoURL = fs.String("app-logo-url", envStringOrDefault("APP_LOGO_URL", "/static/logo.webp"), "URL of the logo shown on the login page, either an absolute path or an http:// or https:// URL.")
+
+		fLdapServer          = fs.String("ldap-server", envStringOrDefault("LDAP_SERVER", ""), "LDAP server URI, has to begin with `ldap://` or `ldaps://`. If this is an ActiveDirectory server, this *has* to be `ldaps://`. `ldap://` additionally requires --allow-insecure-bind.")
+		fLdapPort            = fs.Int("ldap-port", envIntOrDefault("LDAP_PORT", 0), "Port of the LDAP server, overrides a port given in --ldap-server. 0 uses the port from --ldap-server, or the default port of its scheme.")
+		fAllowInsecureBind   = fs.Bool("allow-insecure-bind", envBoolOrDefault("ALLOW_INSECURE_BIND", false), "Allow binding with credentials over an unencrypted `ldap://` connection. Only use this for testing.")
+		fIsActiveDirectory   = fs.Bool("active-directory", envBoolOrDefault("LDAP_IS_AD", false), "Mark the LDAP server as ActiveDirectory.")
+		fBaseDN              = fs.String("base-dn", envStringOrDefault("LDAP_BASE_DN", ""), "Base DN of your LDAP directory.")
+		fReadonlyUser        = fs.String("readonly-user", envStringOrDefault("LDAP_READONLY_USER", ""), "User that can read all users in your LDAP directory.")
+		fReadonlyPassword    = fs.String("readonly-password", envStringOrDefault("LDAP_READONLY_PASSWORD", ""), "Password for the readonly user.")
+		fUserFilter          = fs.String("ldap-user-filter", envStringOrDefault("LDAP_USER_FILTER", ""), "LDAP filter selecting the users kept in the cache, e.g. (!(sAMAccountName=svc-*)). Only objects matching (objectClass=user) are considered. All users are kept when empty.")
+		fGroupFilter         = fs.String("ldap-group-filter", envStringOrDefault("LDAP_GROUP_FILTER", ""), "LDAP filter selecting the groups kept in the cache. Only objects matching (objectClass=group) are considered. All groups are kept when empty.")
+		fComputerFilter      = fs.String("ldap-computer-filter", envStringOrDefault("LDAP_COMPUTER_FILTER", ""), "LDAP filter selecting the computers kept in the cache. Only objects matching (objectClass=computer) are considered. All computers are kept when empty.")
+		fSearchControls      = fs.String("ldap-search-controls", envStringOrDefault("LDAP_SEARCH_CONTROLS", ""), "Comma separated LDAP controls sent with the searches filling the cache. Valid values are: server-side-sort (sorts by sAMAccountName on the server, lists keep that order instead of --cache-sort).")
+		fSlowQueryThreshold  = fs.Duration("slow-query-threshold", envDurationOrDefault("SLOW_QUERY_THRESHOLD", 0), "LDAP operations taking longer than this are logged as slow. 0 disables the logging.")
+		fSelfTestInterval    = fs.Duration("self-test-interval", envDurationOrDefault("SELF_TEST_INTERVAL", 0), "Interval in which a test search is run against the LDAP server, the result is reported in /health. 0 disables the self-test.")
+		fSelfTestBaseDN      = fs.String("self-test-base-dn", envStringOrDefault("SELF_TEST_BASE_DN", ""), "Base DN of the self-test search. Defaults to --base-dn when empty.")
+		fSelfTestFilter      = fs.String("self-test-filter", envStringOrDefault("SELF_TEST_FILTER", "(objectClass=*)"), "LDAP filter of the self-test search.")
+		fSelfTestScope       = fs.String("self-test-scope", envStringOrDefault("SELF_TEST_SCOPE", "base"), "Scope of the self-test search. Valid values are: base, one, sub.")
+		fCacheRefreshCron    = fs.String("cache-refresh-cron", envStringOrDefault("CACHE_REFRESH_CRON", ""), "Cron expression (minute hour day-of-month month day-of-week) scheduling the LDAP cache refreshes, evaluated in local time. Refreshes every 30 seconds when empty.")
+		fCacheRefreshStagger = fs.Bool("cache-refresh-stagger", envBoolOrDefault("CACHE_REFRESH_STAGGER", false), "Whether users, groups and computers are refreshed one after another spread over the refresh interval, instead of all at once.")
+		fCacheMaxDrop        = fs.Float64("cache-max-drop-percent", envFloatOrDefault("CACHE_MAX_DROP_PERCENT", 50), "Percentage of users, groups or computers a refresh may lose before it is held back until the next refresh confirms it. 0 disables the check.")
+		fCacheIndexShards    = fs.Int("cache-index-shards", envIntOrDefault("CACHE_INDEX_SHARDS", 1), "Amount of shards the DN lookups of each cache are split into, each with its own lock, to reduce lock contention under many concurrent requests during refreshes. 1 uses a single lock.")
+		fStaleServeMaxAge    = fs.Duration("stale-serve-max-age", envDurationOrDefault("STALE_SERVE_MAX_AGE", 0), "Age of the cache after which pages and API endpoints answer with 503 while refreshes fail. 0 serves stale data indefinitely.")
+		fCacheSort           = fs.String("cache-sort", envStringOrDefault("CACHE_SORT", string(ldap_cache.SortByCN)), "Order in which lists are shown unless a page asks for another one. Valid values are: cn, name (sAMAccountName, groups use their CN), dn.")
+		fMaxConcurrentBinds  = fs.Int("ldap-max-concurrent-binds", envIntOrDefault("LDAP_MAX_CONCURRENT_BINDS", 0), "Maximum amount of simultaneous authenticated binds, further binds wait for a free slot. 0 means unlimited.")
+		fBindWaitWarning     = fs.Duration("ldap-bind-wait-warning", envDurationOrDefault("LDAP_BIND_WAIT_WARNING", 0), "Time waiting for a free bind slot after which a warning is logged. 0 disables the warning. (Only used when --ldap-max-concurrent-binds is set)")
+
+		fPersistSessions        = fs.Bool("persist-sessions", envBoolOrDefault("PERSIST_SESSIONS", false), "Whether or not to persist sessions into a Bolt database. Useful for development.")
+		fSessionPath            = fs.String("session-path", envStringOrDefault("SESSION_PATH", "db.bbolt"), "Path to the session database file. (Only required when --persist-sessions is set)")
+		fSessionDuration        = fs.Duration("session-duration", envDurationOrDefault("SESSION_DURATION", 30*time.Minute), "Duration of the session. (Only required when --persist-sessions is set)")
+		fSessionCleanupInterval = fs.Duration("session-cleanup-interval", envDurationOrDefault("SESSION_CLEANUP_INTERVAL", time.Hour), "Interval in which expired sessions are removed from the session database. (Only used when --persist-sessions is set)")
+		fCookieSecure           = fs.String("cookie-secure", envStringOrDefault("COOKIE_SECURE", string(CookieSecureAuto)), "Whether the session cookie is marked as secure. Valid values are: auto (only for HTTPS requests), true, false.")
+		fTrustedProxies         = fs.String("trusted-proxies", envStringOrDefault("TRUSTED_PROXIES", ""), "Comma separated IPs or CIDRs of reverse proxies whose X-Forwarded-Proto header is trusted to detect HTTPS, and whose X-Forwarded-For header is trusted as the client address, e.g. for --login-max-failures. The proxies have to set X-Forwarded-For to the client address instead of appending to it.")
+		fLogoutOnInvalidCreds   = fs.Bool("logout-on-invalid-credentials", envBoolOrDefault("LOGOUT_ON_INVALID_CREDENTIALS", true), "Whether users are logged out when the directory rejects their credentials during an operation, e.g. after their password was changed.")
+		fLoginMaxFailures       = fs.Int("login-max-failures", envIntOrDefault("LOGIN_MAX_FAILURES", 5), "Failed logins and credential verifications per client address and per username after which further attempts are answered with 429 until --login-failure-window has passed. 0 disables the limit.")
+		fLoginFailureWindow     = fs.Duration("login-failure-window", envDurationOrDefault("LOGIN_FAILURE_WINDOW", 15*time.Minute), "Time window in which failed logins are counted. (Only used when --login-max-failures is set)")
+		fAuthVerifyEnabled      = fs.Bool("auth-verify-enabled", envBoolOrDefault("AUTH_VERIFY_ENABLED", false), "Whether POST /api/v1/auth/verify checks credentials without a session, e.g. for reverse proxy authentication.")
+
+		fRequestLog      = fs.Bool("request-log-enabled", envBoolOrDefault("REQUEST_LOG_ENABLED", false), "Whether every request is logged with its method, path, status, duration, user DN and request ID. Use \"ldap-manager request-log\" to filter a JSON log.")
+		fRequestTimeout  = fs.Duration("request-timeout", envDurationOrDefault("REQUEST_TIMEOUT", 0), "Maximum time a read request may take before it is answered with 503. No further LDAP operations are started for any request after it, a running one is awaited, so the answer can be delayed by its duration. 0 disables the timeout.")
+		fShutdownTimeout = fs.Duration("shutdown-timeout", envDurationOrDefault("SHUTDOWN_TIMEOUT", 30*time.Second), "Maximum time to wait for open requests when shutting down.")
+		fMaxRequests     = fs.Int("max-concurrent-requests", envIntOrDefault("MAX_CONCURRENT_REQUESTS", 0), "Maximum amount of requests handled at the same time, further requests are answered with 503. 0 means unlimited.")
+		fMaxDNLength     = fs.Int("max-dn-length", envIntOrDefault("MAX_DN_LENGTH", 1024), "Maximum length of a DN in a request path, longer DNs are answered with 400.")
+		fMaxDNDepth      = fs.Int("max-dn-depth", envIntOrDefault("MAX_DN_DEPTH", 32), "Maximum amount of components of a DN in a request path, deeper DNs are answered with 400.")
+		fCompressionMin  = fs.Int("compression-min-size", envIntOrDefault("COMPRESSION_MIN_SIZE", 1024), "Responses smaller than this amount of bytes are sent uncompressed. 0 compresses all responses.")
+		fCSPPolicy       = fs.String("csp-policy", envStringOrDefault("CSP_POLICY", ""), "Value of the Content-Security-Policy header sent with every response. No header is sent when empty.")
 
-		fLdapServer        = flag.String("ldap-server", envStringOrDefault("LDAP_SERVER", ""), "LDAP server URI, has to begin with `ldap://` or `ldaps://`. If this is an ActiveDirectory server, this *has* to be `ldaps://`.")
-		fIsActiveDirectory = flag.Bool("active-directory", envBoolOrDefault("LDAP_IS_AD", false), "Mark the LDAP server as ActiveDirectory.")
-		fBaseDN            = flag.String("base-dn", envStringOrDefault("LDAP_BASE_DN", ""), "Base DN of your LDAP directory.")
-		fReadonlyUser      = flag.String("readonly-user", envStringOrDefault("LDAP_READONLY_USER", ""), "User that can read all users in your LDAP directory.")
-		fReadonlyPassword  = flag.String("readonly-password", envStringOrDefault("LDAP_READONLY_PASSWORD", ""), "Password for the readonly user.")
+		fDefaultLanding      = fs.String("default-landing", envStringOrDefault("DEFAULT_LANDING", string(LandingProfile)), "Page shown at / after login. Valid values are: profile, users, groups, computers, dashboard.")
+		fShowDisabledDefault = fs.Bool("show-disabled-default", envBoolOrDefault("SHOW_DISABLED_DEFAULT", false), "Whether disabled users and computers are shown until a user toggles it themselves.")
+		fHTMXPartials        = fs.Bool("htmx-partials", envBoolOrDefault("HTMX_PARTIALS", true), "Whether pages requested by HTMX (with the HX-Request header) are rendered as fragments without the layout.")
+		fCacheAgeHeaderHTML  = fs.Bool("cache-age-header-html", envBoolOrDefault("CACHE_AGE_HEADER_HTML", false), "Whether the X-Cache-Age header is also sent with HTML pages, not only with the JSON API.")
+		fEditableAttributes  = fs.String("editable-attributes", envStringOrDefault("EDITABLE_ATTRIBUTES", ""), "Comma separated LDAP attributes of users which can be edited in the UI, e.g. description,telephoneNumber,title. Editing is disabled when empty.")
+		fLDIFExportAttrs     = fs.String("ldif-export-attributes", envStringOrDefault("LDIF_EXPORT_ATTRIBUTES", ""), "Comma separated LDAP attributes included in the LDIF export, e.g. cn,sAMAccountName,memberOf. The DN is always included, the enabled state is included with userAccountControl. All cached attributes are exported when empty.")
+		fBulkMaxUsers        = fs.Int("bulk-max-users", envIntOrDefault("BULK_MAX_USERS", 100), "Maximum amount of users that can be added to a group at once.")
+		fBulkConcurrency     = fs.Int("bulk-concurrency", envIntOrDefault("BULK_CONCURRENCY", 4), "Maximum amount of users added to a group at the same time during a bulk add.")
+		fDNValidation        = fs.String("dn-validation", envStringOrDefault("DN_VALIDATION", string(DNValidationReject)), "What to do when a modification refers to a DN which is not in the cache. Valid values are: warn (only log it), reject (answer with 400).")
 
-		fPersistSessions = flag.Bool("persist-sessions", envBoolOrDefault("PERSIST_SESSIONS", false), "Whether or not to persist sessions into a Bolt database. Useful for development.")
-		fSessionPath     = flag.String("session-path", envStringOrDefault("SESSION_PATH", "db.bbolt"), "Path to the session database file. (Only required when --persist-sessions is set)")
-		fSessionDuration = flag.Duration("session-duration", envDurationOrDefault("SESSION_DURATION", 30*time.Minute), "Duration of the session. (Only required when --persist-sessions is set)")
+		fWebhookURL    = fs.String("webhook-url", envStringOrDefault("WEBHOOK_URL", ""), "URL that receives a JSON POST request after every successful modification. Disabled when empty.")
+		fWebhookSecret = fs.String("webhook-secret", envStringOrDefault("WEBHOOK_SECRET", ""), "Secret used to sign webhook payloads with HMAC-SHA256. (Only used when --webhook-url is set)")
 	)
 
-	if !flag.Parsed() {
-		flag.Parse()
-	}
+	// With flag.ExitOnError, parse errors exit and are never returned.
+	_ = fs.Parse(os.Args[1:])
+	checkConfigKeys(config, configKeys)
 
 	logLevel, err := zerolog.ParseLevel(*fLogLevel)
 	if err != nil {
 		log.Fatal().Err(err).Msg("could not parse log level")
 	}
 
+	logFormat := LogFormat(*fLogFormat)
+	if logFormat != LogFormatConsole && logFormat != LogFormatJSON {
+		log.Fatal().Msgf("the option --log-format has to be either \"console\" or \"json\", got \"%s\"", *fLogFormat)
+	}
+
+	mode := Mode(*fMode)
+	if mode != ModeFull && mode != ModeMonitor {
+		log.Fatal().Msgf("the option --mode has to be either \"full\" or \"monitor\", got \"%s\"", *fMode)
+	}
+
+	if *fLogSampleRate < 1 {
+		log.Fatal().Msg("the option --log-sample-rate must be at least 1")
+	}
+
+	panicWhenEmpty("app-title", fAppTitle)
+	if !isSafeLogoURL(*fAppLogoURL) {
+		log.Fatal().Msgf("the option --app-logo-url has to be an absolute path or an http:// or https:// URL, got \"%s\"", *fAppLogoURL)
+	}
+
 	panicWhenEmpty("ldap-server", fLdapServer)
 	panicWhenEmpty("base-dn", fBaseDN)
 	panicWhenEmpty("readonly-user", fReadonlyUser)
 	panicWhenEmpty("readonly-password", fReadonlyPassword)
 
+	if !strings.HasPrefix(strings.ToLower(*fLdapServer), "ldaps://") {
+		if !*fAllowInsecureBind {
+			log.Fatal().Msg("refusing to send credentials over the unencrypted connection to --ldap-server, use `ldaps://` or set --allow-insecure-bind")
+		}
+
+		log.Warn().Msg("credentials are sent in plaintext to the LDAP server because --allow-insecure-bind is set, do not use this in production")
+	}
+
 	if *fPersistSessions {
 		panicWhenEmpty("session-path", fSessionPath)
 	}
 
+	if *fSessionCleanupInterval <= 0 {
+		log.Fatal().Msg("the option --session-cleanup-interval must be greater than 0")
+	}
+
+	if *fLoginMaxFailures < 0 {
+		log.Fatal().Msg("the option --login-max-failures must not be negative")
+	}
+
+	if *fLoginMaxFailures > 0 && *fLoginFailureWindow <= 0 {
+		log.Fatal().Msg("the option --login-failure-window must be positive")
+	}
+
+	if *fAuthVerifyEnabled && *fLoginMaxFailures == 0 {
+		log.Warn().Msg("the option --auth-verify-enabled is set without --login-max-failures, passwords can be guessed without limit")
+	}
+
+	cookieSecure := CookieSecure(*fCookieSecure)
+	if cookieSecure != CookieSecureAuto && cookieSecure != CookieSecureAlways && cookieSecure != CookieSecureNever {
+		log.Fatal().Msgf("the option --cookie-secure has to be one of \"auto\", \"true\" or \"false\", got \"%s\"", *fCookieSecure)
+	}
+
+	trustedProxies := make([]string, 0)
+	for _, proxy := range strings.Split(*fTrustedProxies, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			log.Fatal().Msgf("the option --trusted-proxies contains \"%s\", which is neither an IP nor a CIDR", proxy)
+		}
+
+		trustedProxies = append(trustedProxies, proxy)
+	}
+
+	if *fSlowQueryThreshold < 0 {
+		log.Fatal().Msg("the option --slow-query-threshold must not be negative")
+	}
+
+	if *fSelfTestInterval < 0 {
+		log.Fatal().Msg("the option --self-test-interval must not be negative")
+	}
+
+	if _, err := goldap.CompileFilter(*fSelfTestFilter); err != nil {
+		log.Fatal().Err(err).Msgf("the option --self-test-filter has to be a valid LDAP filter, got \"%s\"", *fSelfTestFilter)
+	}
+
+	userFilter := parseFilter("ldap-user-filter", *fUserFilter)
+	groupFilter := parseFilter("ldap-group-filter", *fGroupFilter)
+	computerFilter := parseFilter("ldap-computer-filter", *fComputerFilter)
+	searchControls := parseSearchControls(*fSearchControls)
+
+	selfTestScope, ok := selfTestScopes[*fSelfTestScope]
+	if !ok {
+		log.Fatal().Msgf("the option --self-test-scope has to be one of \"base\", \"one\" or \"sub\", got \"%s\"", *fSelfTestScope)
+	}
+
+	selfTestBaseDN := *fSelfTestBaseDN
+	if selfTestBaseDN == "" {
+		selfTestBaseDN = *fBaseDN
+	}
+
+	var cacheRefreshSchedule *cron.Schedule
+	if *fCacheRefreshCron != "" {
+		schedule, err := cron.Parse(*fCacheRefreshCron)
+		if err != nil {
+			log.Fatal().Err(err).Msgf("the option --cache-refresh-cron has to be a valid cron expression, got \"%s\"", *fCacheRefreshCron)
+		}
+
+		cacheRefreshSchedule = schedule
+
+		if *fCacheRefreshStagger {
+			log.Warn().Msg("the option --cache-refresh-stagger has no effect together with --cache-refresh-cron")
+		}
+	}
+
+	cacheSort := ldap_cache.SortOrder(*fCacheSort)
+	switch cacheSort {
+	case ldap_cache.SortByCN, ldap_cache.SortByName, ldap_cache.SortByDN:
+	default:
+		log.Fatal().Msgf("the option --cache-sort has to be one of \"cn\", \"name\" or \"dn\", got \"%s\"", *fCacheSort)
+	}
+
+	editableAttributes := parseAttributes("editable-attributes", *fEditableAttributes)
+	ldifExportAttributes := parseAttributes("ldif-export-attributes", *fLDIFExportAttrs)
+
+	if *fCacheMaxDrop < 0 || *fCacheMaxDrop > 100 {
+		log.Fatal().Msg("the option --cache-max-drop-percent must be between 0 and 100")
+	}
+
+	if *fCacheIndexShards < 1 {
+		log.Fatal().Msg("the option --cache-index-shards must be at least 1")
+	}
+
+	if *fStaleServeMaxAge < 0 {
+		log.Fatal().Msg("the option --stale-serve-max-age must not be negative")
+	}
+
+	if *fCompressionMin < 0 {
+		log.Fatal().Msg("the option --compression-min-size must not be negative")
+	}
+
+	if *fMaxConcurrentBinds < 0 {
+		log.Fatal().Msg("the option --ldap-max-concurrent-binds must not be negative")
+	}
+
+	if *fBindWaitWarning < 0 {
+		log.Fatal().Msg("the option --ldap-bind-wait-warning must not be negative")
+	}
+
+	if *fRequestTimeout < 0 {
+		log.Fatal().Msg("the option --request-timeout must not be negative")
+	}
+
+	if *fShutdownTimeout <= 0 {
+		log.Fatal().Msg("the option --shutdown-timeout must be positive")
+	}
+
+	if *fMaxRequests < 0 {
+		log.Fatal().Msg("the option --max-concurrent-requests must not be negative")
+	}
+
+	if *fMaxDNLength < 1 {
+		log.Fatal().Msg("the option --max-dn-length must be at least 1")
+	}
+
+	if *fMaxDNDepth < 1 {
+		log.Fatal().Msg("the option --max-dn-depth must be at least 1")
+	}
+
+	if *fCSPPolicy != "" && (strings.TrimSpace(*fCSPPolicy) == "" || strings.ContainsAny(*fCSPPolicy, "\r\n")) {
+		log.Fatal().Msg("the option --csp-policy must not be blank or contain line breaks")
+	}
+
+	if *fBulkMaxUsers < 1 {
+		log.Fatal().Msg("the option --bulk-max-users must be at least 1")
+	}
+
+	defaultLanding := Landing(*fDefaultLanding)
+	switch defaultLanding {
+	case LandingProfile, LandingUsers, LandingGroups, LandingComputers, LandingDashboard:
+	default:
+		log.Fatal().Msgf("the option --default-landing has to be one of \"profile\", \"users\", \"groups\", \"computers\" or \"dashboard\", got \"%s\"", *fDefaultLanding)
+	}
+
+	if *fBulkConcurrency < 1 {
+		log.Fatal().Msg("the option --bulk-concurrency must be at least 1")
+	}
+
+	dnValidation := DNValidation(*fDNValidation)
+	if dnValidation != DNValidationWarn && dnValidation != DNValidationReject {
+		log.Fatal().Msgf("the option --dn-validation has to be either \"warn\" or \"reject\", got \"%s\"", *fDNValidation)
+	}
+
+	if *fWebhookURL != "" {
+		if u, err := url.Parse(*fWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			log.Fatal().Msgf("the option --webhook-url has to be an absolute http:// or https:// URL, got \"%s\"", *fWebhookURL)
+		}
+	}
+
+	ldapServer := *fLdapServer
+	if *fLdapPort != 0 {
+		if *fLdapPort < 0 || *fLdapPort > 65535 {
+			log.Fatal().Msgf("the option --ldap-port must be between 1 and 65535, got %d", *fLdapPort)
+		}
+
+		ldapServer, err = withPort(ldapServer, *fLdapPort)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not apply --ldap-port to --ldap-server")
+		}
+	}
+
 	ldapConfig := ldap.Config{
-		Server:            *fLdapServer,
+		Server:            ldapServer,
 		BaseDN:            *fBaseDN,
 		IsActiveDirectory: *fIsActiveDirectory,
 	}
 
+	if mode == ModeMonitor && *fSelfTestInterval == 0 {
+		log.Warn().Msg("running in monitor mode without --self-test-interval, /health only reports that the process is alive")
+	}
+
 	return &Opts{
-		LogLevel: logLevel,
+		Mode: mode,
+
+		LogLevel:         logLevel,
+		LogFormat:        logFormat,
+		LogSampleRate:    uint32(*fLogSampleRate),
+		LogConfigSummary: *fLogConfigSummary,
+
+		AppTitle:   *fAppTitle,
+		AppLogoURL: *fAppLogoURL,
+
+		LDAP:                   ldapConfig,
+		AllowInsecureBind:      *fAllowInsecureBind,
+		ReadonlyUser:           *fReadonlyUser,
+		ReadonlyPassword:       *fReadonlyPassword,
+		LDAPUserFilter:         userFilter,
+		LDAPGroupFilter:        groupFilter,
+		LDAPComputerFilter:     computerFilter,
+		LDAPSearchControls:     searchControls,
+		LDAPMaxConcurrentBinds: *fMaxConcurrentBinds,
+		LDAPBindWaitWarning:    *fBindWaitWarning,
+		SlowQueryThreshold:     *fSlowQueryThreshold,
+		SelfTestInterval:       *fSelfTestInterval,
+		SelfTestBaseDN:         selfTestBaseDN,
+		SelfTestFilter:         *fSelfTestFilter,
+		SelfTestScope:          selfTestScope,
+		CacheRefreshSchedule:   cacheRefreshSchedule,
+		CacheRefreshStagger:    *fCacheRefreshStagger,
+		CacheSort:              cacheSort,
+		CacheMaxDropPercent:    *fCacheMaxDrop,
+		CacheIndexShards:       *fCacheIndexShards,
+		StaleServeMaxAge:       *fStaleServeMaxAge,
+
+		PersistSessions:        *fPersistSessions,
+		SessionPath:            *fSessionPath,
+		SessionDuration:        *fSessionDuration,
+		SessionCleanupInterval: *fSessionCleanupInterval,
+		CookieSecure:           cookieSecure,
+		TrustedProxies:         trustedProxies,
+		LogoutOnInvalidCreds:   *fLogoutOnInvalidCreds,
+		LoginMaxFailures:       *fLoginMaxFailures,
+		LoginFailureWindow:     *fLoginFailureWindow,
+		AuthVerifyEnabled:      *fAuthVerifyEnabled,
+
+		RequestLogEnabled:     *fRequestLog,
+		RequestTimeout:        *fRequestTimeout,
+		ShutdownTimeout:       *fShutdownTimeout,
+		MaxConcurrentRequests: *fMaxRequests,
+		MaxDNLength:           *fMaxDNLength,
+		MaxDNDepth:            *fMaxDNDepth,
+		CSPPolicy:             strings.TrimSpace(*fCSPPolicy),
+		CompressionMinSize:    *fCompressionMin,
 
-		LDAP:             ldapConfig,
-		ReadonlyUser:     *fReadonlyUser,
-		ReadonlyPassword: *fReadonlyPassword,
+		DefaultLanding:       defaultLanding,
+		ShowDisabledDefault:  *fShowDisabledDefault,
+		HTMXPartials:         *fHTMXPartials,
+		CacheAgeHeaderHTML:   *fCacheAgeHeaderHTML,
+		EditableAttributes:   editableAttributes,
+		LDIFExportAttributes: ldifExportAttributes,
+		BulkMaxUsers:         *fBulkMaxUsers,
+		BulkConcurrency:      *fBulkConcurrency,
+		DNValidation:         dnValidation,
 
-		PersistSessions: *fPersistSessions,
-		SessionPath:     *fSessionPath,
-		SessionDuration: *fSessionDuration,
+		WebhookURL:    *fWebhookURL,
+		WebhookSecret: *fWebhookSecret,
 	}
 }
@@ -0,0 +1,249 @@
+package options
+
+import (
+	"flag"
+	"io"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/netresearch/ldap-manager/internal/ldap_cache"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func TestEarlyLogFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  string
+		want LogFormat
+	}{
+		{"default", nil, "", LogFormatConsole},
+		{"environment", nil, "json", LogFormatJSON},
+		{"flag", []string{"--log-format", "json"}, "", LogFormatJSON},
+		{"flag overrides environment", []string{"--log-format=console"}, "json", LogFormatConsole},
+		{"invalid value", []string{"--log-format", "xml"}, "", LogFormatConsole},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := os.Args
+			t.Cleanup(func() { os.Args = args })
+			os.Args = append([]string{"ldap-manager"}, tt.args...)
+			t.Setenv("LOG_FORMAT", tt.env)
+
+			if got := EarlyLogFormat(); got != tt.want {
+				t.Errorf("EarlyLogFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithPort(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  string
+		port    int
+		want    string
+		wantErr bool
+	}{
+		{"without port", "ldaps://dc.example.com", 3269, "ldaps://dc.example.com:3269", false},
+		{"replaces port", "ldap://dc.example.com:389", 3268, "ldap://dc.example.com:3268", false},
+		{"same port", "ldaps://dc.example.com:636", 636, "ldaps://dc.example.com:636", false},
+		{"keeps path", "ldaps://dc.example.com/", 636, "ldaps://dc.example.com:636/", false},
+		{"IPv4", "ldaps://192.0.2.1:636", 10636, "ldaps://192.0.2.1:10636", false},
+		{"IPv6", "ldaps://[2001:db8::1]:636", 10636, "ldaps://[2001:db8::1]:10636", false},
+		{"IPv6 without port", "ldaps://[2001:db8::1]", 636, "ldaps://[2001:db8::1]:636", false},
+		{"no host", "ldaps://", 636, "", true},
+		{"no scheme", "dc.example.com", 636, "", true},
+		{"invalid", "ldaps://%zz", 636, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := withPort(tt.server, tt.port)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("withPort() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("withPort() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// fatalError is raised instead of exiting when Parse fails in tests.
+type fatalError string
+
+// panicOnFatal turns fatal log events into a fatalError panic, which stops
+// zerolog from exiting the test binary.
+type panicOnFatal struct{}
+
+func (panicOnFatal) Run(_ *zerolog.Event, level zerolog.Level, msg string) {
+	if level == zerolog.FatalLevel {
+		panic(fatalError(msg))
+	}
+}
+
+// requiredEnv holds the options Parse requires.
+var requiredEnv = map[string]string{
+	"LDAP_SERVER":            "ldaps://dc.example.com",
+	"LDAP_BASE_DN":           "dc=example,dc=com",
+	"LDAP_READONLY_USER":     "cn=reader,dc=example,dc=com",
+	"LDAP_READONLY_PASSWORD": "secret",
+}
+
+// parse runs Parse with the arguments and the required options, overridden
+// by env. If Parse fails, the message is returned instead of exiting.
+func parse(t *testing.T, args []string, env map[string]string) (opts *Opts, fatal string) {
+	t.Helper()
+
+	osArgs, logger := os.Args, log.Logger
+	t.Cleanup(func() {
+		os.Args, log.Logger = osArgs, logger
+	})
+	os.Args = append([]string{"ldap-manager"}, args...)
+	log.Logger = zerolog.New(io.Discard).Hook(panicOnFatal{})
+
+	for name, value := range requiredEnv {
+		t.Setenv(name, value)
+	}
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			msg, ok := r.(fatalError)
+			if !ok {
+				panic(r)
+			}
+
+			fatal = string(msg)
+		}
+	}()
+
+	return Parse(), ""
+}
+
+func TestParseFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    [3]string
+		wantErr bool
+	}{
+		{"default", nil, [3]string{}, false},
+		{
+			"custom",
+			map[string]string{
+				"LDAP_USER_FILTER":     "(!(sAMAccountName=svc-*))",
+				"LDAP_GROUP_FILTER":    " (cn=app-*) ",
+				"LDAP_COMPUTER_FILTER": "(operatingSystem=Windows*)",
+			},
+			[3]string{"(!(sAMAccountName=svc-*))", "(cn=app-*)", "(operatingSystem=Windows*)"},
+			false,
+		},
+		{"invalid user filter", map[string]string{"LDAP_USER_FILTER": "(cn=a"}, [3]string{}, true},
+		{"invalid group filter", map[string]string{"LDAP_GROUP_FILTER": "cn=a)"}, [3]string{}, true},
+		{"invalid computer filter", map[string]string{"LDAP_COMPUTER_FILTER": "(&(cn=a)"}, [3]string{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, fatal := parse(t, nil, tt.env)
+			if (fatal != "") != tt.wantErr {
+				t.Fatalf("Parse() failed with %q, want error %v", fatal, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := [3]string{opts.LDAPUserFilter, opts.LDAPGroupFilter, opts.LDAPComputerFilter}
+			if got != tt.want {
+				t.Errorf("filters = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSearchControls(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []ldap_cache.SearchControl
+		wantErr bool
+	}{
+		{"none", "", []ldap_cache.SearchControl{}, false},
+		{"server-side sort", "server-side-sort", []ldap_cache.SearchControl{ldap_cache.ControlServerSideSort}, false},
+		{"duplicates", " server-side-sort,server-side-sort ", []ldap_cache.SearchControl{ldap_cache.ControlServerSideSort}, false},
+		{"unknown", "show-deleted", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, fatal := parse(t, nil, map[string]string{"LDAP_SEARCH_CONTROLS": tt.value})
+			if (fatal != "") != tt.wantErr {
+				t.Fatalf("Parse() failed with %q, want error %v", fatal, tt.wantErr)
+			}
+
+			if !tt.wantErr && !slices.Equal(opts.LDAPSearchControls, tt.want) {
+				t.Errorf("controls = %v, want %v", opts.LDAPSearchControls, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInsecureBind(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  string
+		allow   string
+		wantErr bool
+	}{
+		{"ldaps", "ldaps://dc.example.com", "", false},
+		{"ldaps with upper case scheme", "LDAPS://dc.example.com", "", false},
+		{"ldap", "ldap://dc.example.com", "", true},
+		{"ldap not allowed", "ldap://dc.example.com", "false", true},
+		{"ldap allowed", "ldap://dc.example.com", "true", false},
+		{"ldaps allowed", "ldaps://dc.example.com", "true", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, fatal := parse(t, nil, map[string]string{
+				"LDAP_SERVER":         tt.server,
+				"ALLOW_INSECURE_BIND": tt.allow,
+			})
+			if (fatal != "") != tt.wantErr {
+				t.Fatalf("Parse() failed with %q, want error %v", fatal, tt.wantErr)
+			}
+
+			if !tt.wantErr && opts.LDAP.Server != tt.server {
+				t.Errorf("LDAP server = %s, want %s", opts.LDAP.Server, tt.server)
+			}
+		})
+	}
+}
+
+func TestParseTwice(t *testing.T) {
+	first, fatal := parse(t, []string{"--app-title", "First"}, nil)
+	if fatal != "" {
+		t.Fatal(fatal)
+	}
+
+	second, fatal := parse(t, []string{"--app-title", "Second"}, nil)
+	if fatal != "" {
+		t.Fatal(fatal)
+	}
+
+	if first.AppTitle != "First" || second.AppTitle != "Second" {
+		t.Errorf("app titles = %q and %q, want First and Second", first.AppTitle, second.AppTitle)
+	}
+
+	if flag.CommandLine.Lookup("app-title") != nil {
+		t.Error("Parse() registered its flags globally")
+	}
+}
@@ -0,0 +1,234 @@
+package options
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// envNames collects the environment variables read for the options, which
+// are the keys a config file may set. The names are the same for every call
+// of Parse.
+var envNames = make(map[string]bool)
+
+// configFile returns the config file given by --config or CONFIG_FILE, or an
+// empty string if there is none.
+func configFile() string {
+	if file, ok := flagArg(os.Args[1:], "config"); ok {
+		return file
+	}
+
+	return os.Getenv("CONFIG_FILE")
+}
+
+// loadConfigFile sets the environment variables from the config file which
+// are not set yet, so the file takes precedence over the defaults only. It
+// returns the keys of the file, which are checked by checkConfigKeys once all
+// options are known.
+func loadConfigFile(file string) []string {
+	values, err := parseConfigFile(file)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("could not load config file %s", file)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key, value := range values {
+		keys = append(keys, key)
+
+		if os.Getenv(key) == "" {
+			if err := os.Setenv(key, value); err != nil {
+				log.Fatal().Err(err).Msgf("could not load config file %s", file)
+			}
+		}
+	}
+	slices.Sort(keys)
+
+	log.Info().Msgf("loaded config file %s", file)
+
+	return keys
+}
+
+// checkConfigKeys rejects keys of the config file which are no option, so a
+// typo does not silently fall back to the default.
+func checkConfigKeys(file string, keys []string) {
+	for _, key := range keys {
+		if !envNames[key] {
+			log.Fatal().Msgf("the config file %s contains the unknown option \"%s\"", file, key)
+		}
+	}
+}
+
+// parseConfigFile reads a config file in TOML. Only top level keys are
+// supported, named like the environment variables, e.g.
+//
+//	LDAP_SERVER = "ldaps://dc.example.com"
+//	SESSION_DURATION = "30m"
+//	PERSIST_SESSIONS = true
+//	TRUSTED_PROXIES = ["10.0.0.1", "10.0.0.2"]
+//
+// Arrays are joined with commas for the options taking comma separated lists.
+func parseConfigFile(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(text, "[") {
+			return nil, fmt.Errorf("line %d: tables are not supported, all options are top level keys", line)
+		}
+
+		key, raw, found := strings.Cut(text, "=")
+		if !found {
+			return nil, fmt.Errorf("line %d: expected key = value", line)
+		}
+
+		key = strings.TrimSpace(key)
+		if !isBareKey(key) {
+			return nil, fmt.Errorf("line %d: invalid key \"%s\"", line, key)
+		}
+		if _, exists := values[key]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key \"%s\"", line, key)
+		}
+
+		value, err := parseConfigValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		values[key] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func isBareKey(key string) bool {
+	if key == "" {
+		return false
+	}
+
+	for _, r := range key {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseConfigValue returns a string, an array of strings joined with commas,
+// or a number or boolean as it is written. A trailing comment is removed.
+func parseConfigValue(raw string) (string, error) {
+	if strings.HasPrefix(raw, "[") {
+		items := make([]string, 0)
+		rest := strings.TrimSpace(raw[1:])
+		for !strings.HasPrefix(rest, "]") {
+			item, tail, err := parseConfigString(rest)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, item)
+
+			rest = strings.TrimSpace(tail)
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimSpace(rest[1:])
+			} else if !strings.HasPrefix(rest, "]") {
+				return "", fmt.Errorf("expected a comma or the end of the array in %s", raw)
+			}
+		}
+
+		return strings.Join(items, ","), checkTrailing(rest[1:])
+	}
+
+	if strings.HasPrefix(raw, `"`) || strings.HasPrefix(raw, "'") {
+		value, tail, err := parseConfigString(raw)
+		if err != nil {
+			return "", err
+		}
+
+		return value, checkTrailing(tail)
+	}
+
+	value, _, _ := strings.Cut(raw, "#")
+	value = strings.TrimSpace(value)
+	if value == "true" || value == "false" {
+		return value, nil
+	}
+	// TOML allows underscores between digits, e.g. 1_000.
+	if number := strings.ReplaceAll(value, "_", ""); isNumber(number) {
+		return number, nil
+	}
+
+	return "", fmt.Errorf("invalid value %s, strings have to be quoted", raw)
+}
+
+// isNumber reports whether value is a decimal integer or float.
+func isNumber(value string) bool {
+	if strings.Trim(value, "0123456789+-.eE") != "" {
+		return false
+	}
+
+	_, err := strconv.ParseFloat(value, 64)
+
+	return err == nil
+}
+
+// parseConfigString parses the basic or literal string at the start of raw
+// and returns the rest of raw behind it.
+func parseConfigString(raw string) (value, rest string, err error) {
+	if strings.HasPrefix(raw, "'") {
+		end := strings.Index(raw[1:], "'")
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string %s", raw)
+		}
+
+		return raw[1 : end+1], raw[end+2:], nil
+	}
+
+	if !strings.HasPrefix(raw, `"`) {
+		return "", "", fmt.Errorf("expected a quoted string at %s", raw)
+	}
+
+	for i := 1; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++
+		case '"':
+			value, err := strconv.Unquote(raw[:i+1])
+			if err != nil {
+				return "", "", fmt.Errorf("invalid string %s: %w", raw[:i+1], err)
+			}
+
+			return value, raw[i+1:], nil
+		}
+	}
+
+	return "", "", fmt.Errorf("unterminated string %s", raw)
+}
+
+// checkTrailing allows only a comment behind a value.
+func checkTrailing(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("unexpected %s behind the value", rest)
+	}
+
+	return nil
+}
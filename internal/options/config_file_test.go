@@ -0,0 +1,156 @@
+package options
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "ldap-manager.toml")
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func TestParseConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "values",
+			content: `# LDAP Manager
+LDAP_SERVER = "ldaps://dc.example.com" # the primary DC
+LDAP_READONLY_PASSWORD = 'C:\no\escapes'
+APP_TITLE = "Acme \"Directory\""
+SESSION_DURATION = "45m"
+PERSIST_SESSIONS = true
+LOGIN_MAX_FAILURES = 10
+CACHE_MAX_DROP_PERCENT = 12.5
+BULK_MAX_USERS = 1_000
+TRUSTED_PROXIES = ["10.0.0.1", '10.0.0.2'] # both proxies
+EDITABLE_ATTRIBUTES = []
+`,
+			want: map[string]string{
+				"LDAP_SERVER":            "ldaps://dc.example.com",
+				"LDAP_READONLY_PASSWORD": `C:\no\escapes`,
+				"APP_TITLE":              `Acme "Directory"`,
+				"SESSION_DURATION":       "45m",
+				"PERSIST_SESSIONS":       "true",
+				"LOGIN_MAX_FAILURES":     "10",
+				"CACHE_MAX_DROP_PERCENT": "12.5",
+				"BULK_MAX_USERS":         "1000",
+				"TRUSTED_PROXIES":        "10.0.0.1,10.0.0.2",
+				"EDITABLE_ATTRIBUTES":    "",
+			},
+		},
+		{name: "table", content: "[ldap]\nserver = \"ldaps://dc\"\n", wantErr: "line 1: tables are not supported"},
+		{name: "duplicate key", content: "APP_TITLE = \"a\"\nAPP_TITLE = \"b\"\n", wantErr: "line 2: duplicate key"},
+		{name: "unquoted string", content: "LDAP_SERVER = ldaps://dc\n", wantErr: "strings have to be quoted"},
+		{name: "missing value", content: "LDAP_SERVER\n", wantErr: "expected key = value"},
+		{name: "invalid key", content: "LDAP SERVER = \"ldaps://dc\"\n", wantErr: "invalid key"},
+		{name: "unterminated string", content: "LDAP_SERVER = \"ldaps://dc\n", wantErr: "unterminated string"},
+		{name: "unterminated array", content: "TRUSTED_PROXIES = [\"10.0.0.1\"\n", wantErr: "end of the array"},
+		{name: "text behind the value", content: "APP_TITLE = \"a\" \"b\"\n", wantErr: "behind the value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseConfigFile(writeConfigFile(t, tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseConfigFile() error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("parseConfigFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWithConfigFile(t *testing.T) {
+	file := writeConfigFile(t, `LDAP_SERVER = "ldaps://config.example.com"
+LDAP_BASE_DN = "dc=config,dc=com"
+SESSION_DURATION = "45m"
+PERSIST_SESSIONS = true
+TRUSTED_PROXIES = ["10.0.0.1", "10.0.0.2"]
+`)
+
+	// The variables set from the file are restored after the test.
+	for _, name := range []string{"SESSION_DURATION", "PERSIST_SESSIONS", "TRUSTED_PROXIES", "CONFIG_FILE"} {
+		t.Setenv(name, "")
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{"flag", []string{"--config", file}, nil},
+		{"environment", nil, map[string]string{"CONFIG_FILE": file}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The environment and flags take precedence over the file.
+			env := map[string]string{"LDAP_SERVER": "", "LDAP_BASE_DN": "dc=env,dc=com"}
+			maps.Copy(env, tt.env)
+
+			opts, fatal := parse(t, append(tt.args, "--session-duration", "1h"), env)
+			if fatal != "" {
+				t.Fatal(fatal)
+			}
+
+			if opts.LDAP.Server != "ldaps://config.example.com" {
+				t.Errorf("LDAP server = %q, want the one of the config file", opts.LDAP.Server)
+			}
+			if opts.LDAP.BaseDN != "dc=env,dc=com" {
+				t.Errorf("base DN = %q, want the one of the environment", opts.LDAP.BaseDN)
+			}
+			if opts.SessionDuration != time.Hour {
+				t.Errorf("session duration = %s, want the one of the flag", opts.SessionDuration)
+			}
+			if !opts.PersistSessions {
+				t.Error("sessions are not persisted")
+			}
+			if !slices.Equal(opts.TrustedProxies, []string{"10.0.0.1", "10.0.0.2"}) {
+				t.Errorf("trusted proxies = %v, want both of the config file", opts.TrustedProxies)
+			}
+		})
+	}
+}
+
+func TestParseConfigFileUnknownOption(t *testing.T) {
+	file := writeConfigFile(t, "LDAP_SEVER = \"ldaps://dc.example.com\"\n")
+	t.Setenv("LDAP_SEVER", "")
+
+	_, fatal := parse(t, []string{"--config", file}, nil)
+	if !strings.Contains(fatal, `unknown option "LDAP_SEVER"`) {
+		t.Errorf("Parse() failed with %q, want the unknown option to be rejected", fatal)
+	}
+}
+
+func TestParseMissingConfigFile(t *testing.T) {
+	_, fatal := parse(t, []string{"--config", filepath.Join(t.TempDir(), "missing.toml")}, nil)
+	if !strings.Contains(fatal, "could not load config file") {
+		t.Errorf("Parse() failed with %q, want the missing config file to be fatal", fatal)
+	}
+}
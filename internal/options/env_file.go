@@ -0,0 +1,101 @@
+package options
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog/log"
+)
+
+var defaultEnvFiles = []string{".env.local", ".env"}
+
+// flagArg looks up a flag in the command line before the flags are parsed,
+// e.g. --env-file, as the env files provide the defaults of all other flags.
+func flagArg(args []string, flag string) (string, bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != flag {
+			continue
+		}
+
+		if hasValue {
+			return value, true
+		}
+
+		if i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+
+	return "", false
+}
+
+// envFiles returns the dotenv files to load, given as a comma separated list
+// by --env-file or ENV_FILE. explicit is false when the defaults are used.
+func envFiles() (files []string, explicit bool) {
+	raw, ok := flagArg(os.Args[1:], "env-file")
+	if !ok {
+		raw = os.Getenv("ENV_FILE")
+	}
+
+	for _, file := range strings.Split(raw, ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			files = append(files, file)
+		}
+	}
+
+	if len(files) == 0 {
+		return defaultEnvFiles, false
+	}
+
+	return files, true
+}
+
+// requireEnvFile reports whether REQUIRE_ENV_FILE is set. It can only be an
+// environment variable, as it is needed before the flags are parsed.
+func requireEnvFile() bool {
+	raw := os.Getenv("REQUIRE_ENV_FILE")
+	if raw == "" {
+		return false
+	}
+
+	required, err := strconv.ParseBool(raw)
+	if err != nil {
+		log.Fatal().Msgf("could not parse environment variable \"REQUIRE_ENV_FILE\" (containing \"%s\") as bool: %v", raw, err)
+	}
+
+	return required
+}
+
+// loadEnvFiles loads the files in order. Variables which are already set are
+// not overwritten, so earlier files take precedence over later ones.
+//
+// With required set, a file which can not be loaded is fatal. Of the default
+// files, where .env.local is usually missing, at least one has to be loaded.
+func loadEnvFiles(files []string, explicit, required bool) {
+	loaded := 0
+	for _, file := range files {
+		if err := godotenv.Load(file); err != nil {
+			if required && explicit {
+				log.Fatal().Err(err).Msgf("could not load env file %s, which is required by REQUIRE_ENV_FILE", file)
+			}
+
+			log.Warn().Err(err).Msgf("could not load env file %s", file)
+
+			continue
+		}
+
+		loaded++
+		log.Info().Msgf("loaded env file %s", file)
+	}
+
+	if required && loaded == 0 {
+		log.Fatal().Msgf("none of the env files %s could be loaded, which is required by REQUIRE_ENV_FILE", strings.Join(files, ", "))
+	}
+}
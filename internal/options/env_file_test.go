@@ -0,0 +1,32 @@
+package options
+
+import "testing"
+
+func TestFlagArg(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		wantValue string
+		wantOK    bool
+	}{
+		{"missing", []string{"--log-level", "debug"}, "", false},
+		{"separate value", []string{"--env-file", "prod.env"}, "prod.env", true},
+		{"inline value", []string{"--env-file=prod.env"}, "prod.env", true},
+		{"single dash", []string{"-env-file", "prod.env"}, "prod.env", true},
+		{"empty inline value", []string{"--env-file="}, "", true},
+		{"without value", []string{"--env-file"}, "", false},
+		{"after other flags", []string{"--log-level", "debug", "--env-file", "prod.env"}, "prod.env", true},
+		{"after terminator", []string{"--", "--env-file", "prod.env"}, "", false},
+		{"as value of another flag", []string{"env-file"}, "", false},
+		{"prefix of another flag", []string{"--env-file-x", "prod.env"}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, ok := flagArg(tt.args, "env-file")
+			if value != tt.wantValue || ok != tt.wantOK {
+				t.Errorf("flagArg() = (%q, %v), want (%q, %v)", value, ok, tt.wantValue, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package options
+
+import (
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// redacted replaces a secret in the summary, an empty value stays visible so
+// a missing secret can still be spotted.
+func redacted(v string) string {
+	if v == "" {
+		return ""
+	}
+
+	return "***"
+}
+
+// LogSummary logs the effective configuration as a single structured line.
+// Passwords and secrets are redacted, the webhook URL is only reported as
+// set as it may carry a token.
+func (o *Opts) LogSummary(logger zerolog.Logger) {
+	schedule := ""
+	if o.CacheRefreshSchedule != nil {
+		schedule = o.CacheRefreshSchedule.String()
+	}
+
+	controls := make([]string, 0, len(o.LDAPSearchControls))
+	for _, control := range o.LDAPSearchControls {
+		controls = append(controls, string(control))
+	}
+
+	logger.Info().
+		Str("mode", string(o.Mode)).
+		Str("log_level", o.LogLevel.String()).
+		Str("log_format", string(o.LogFormat)).
+		Uint32("log_sample_rate", o.LogSampleRate).
+		Str("app_title", o.AppTitle).
+		Str("app_logo_url", o.AppLogoURL).
+		Str("ldap_server", o.LDAP.Server).
+		Str("ldap_base_dn", o.LDAP.BaseDN).
+		Bool("ldap_is_ad", o.LDAP.IsActiveDirectory).
+		Bool("allow_insecure_bind", o.AllowInsecureBind).
+		Str("ldap_readonly_user", o.ReadonlyUser).
+		Str("ldap_readonly_password", redacted(o.ReadonlyPassword)).
+		Str("ldap_user_filter", o.LDAPUserFilter).
+		Str("ldap_group_filter", o.LDAPGroupFilter).
+		Str("ldap_computer_filter", o.LDAPComputerFilter).
+		Str("ldap_search_controls", strings.Join(controls, ",")).
+		Int("ldap_max_concurrent_binds", o.LDAPMaxConcurrentBinds).
+		Dur("ldap_bind_wait_warning", o.LDAPBindWaitWarning).
+		Dur("slow_query_threshold", o.SlowQueryThreshold).
+		Dur("self_test_interval", o.SelfTestInterval).
+		Str("self_test_base_dn", o.SelfTestBaseDN).
+		Str("self_test_filter", o.SelfTestFilter).
+		Str("cache_refresh_cron", schedule).
+		Bool("cache_refresh_stagger", o.CacheRefreshStagger).
+		Str("cache_sort", string(o.CacheSort)).
+		Float64("cache_max_drop_percent", o.CacheMaxDropPercent).
+		Int("cache_index_shards", o.CacheIndexShards).
+		Dur("stale_serve_max_age", o.StaleServeMaxAge).
+		Bool("persist_sessions", o.PersistSessions).
+		Str("session_path", o.SessionPath).
+		Dur("session_duration", o.SessionDuration).
+		Dur("session_cleanup_interval", o.SessionCleanupInterval).
+		Str("cookie_secure", string(o.CookieSecure)).
+		Str("trusted_proxies", strings.Join(o.TrustedProxies, ",")).
+		Bool("logout_on_invalid_credentials", o.LogoutOnInvalidCreds).
+		Int("login_max_failures", o.LoginMaxFailures).
+		Dur("login_failure_window", o.LoginFailureWindow).
+		Bool("auth_verify_enabled", o.AuthVerifyEnabled).
+		Bool("request_log_enabled", o.RequestLogEnabled).
+		Dur("request_timeout", o.RequestTimeout).
+		Dur("shutdown_timeout", o.ShutdownTimeout).
+		Int("max_concurrent_requests", o.MaxConcurrentRequests).
+		Int("max_dn_length", o.MaxDNLength).
+		Int("max_dn_depth", o.MaxDNDepth).
+		Str("csp_policy", o.CSPPolicy).
+		Int("compression_min_size", o.CompressionMinSize).
+		Str("default_landing", string(o.DefaultLanding)).
+		Bool("show_disabled_default", o.ShowDisabledDefault).
+		Bool("htmx_partials", o.HTMXPartials).
+		Bool("cache_age_header_html", o.CacheAgeHeaderHTML).
+		Str("editable_attributes", strings.Join(o.EditableAttributes, ",")).
+		Str("ldif_export_attributes", strings.Join(o.LDIFExportAttributes, ",")).
+		Int("bulk_max_users", o.BulkMaxUsers).
+		Int("bulk_concurrency", o.BulkConcurrency).
+		Str("dn_validation", string(o.DNValidation)).
+		Bool("webhook", o.WebhookURL != "").
+		Str("webhook_secret", redacted(o.WebhookSecret)).
+		Msg("effective configuration")
+}
@@ -0,0 +1,68 @@
+package options
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestLogSummaryRedactsSecrets(t *testing.T) {
+	opts, fatal := parse(t, nil, map[string]string{
+		"LDAP_READONLY_PASSWORD": "bind-password",
+		"WEBHOOK_URL":            "https://hooks.example.com/notify?token=webhook-token",
+		"WEBHOOK_SECRET":         "webhook-secret",
+	})
+	if fatal != "" {
+		t.Fatal(fatal)
+	}
+
+	var buf bytes.Buffer
+	opts.LogSummary(zerolog.New(&buf))
+
+	for _, secret := range []string{"bind-password", "webhook-token", "webhook-secret"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Errorf("summary contains %s: %s", secret, buf.String())
+		}
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("summary is not valid JSON: %v", err)
+	}
+
+	want := map[string]any{
+		"message":                "effective configuration",
+		"ldap_server":            requiredEnv["LDAP_SERVER"],
+		"ldap_readonly_user":     requiredEnv["LDAP_READONLY_USER"],
+		"ldap_readonly_password": "***",
+		"webhook":                true,
+		"webhook_secret":         "***",
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("%s = %v, want %v", key, entry[key], value)
+		}
+	}
+}
+
+func TestLogSummaryShowsMissingSecrets(t *testing.T) {
+	opts, fatal := parse(t, nil, nil)
+	if fatal != "" {
+		t.Fatal(fatal)
+	}
+
+	var buf bytes.Buffer
+	opts.LogSummary(zerolog.New(&buf))
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("summary is not valid JSON: %v", err)
+	}
+
+	if entry["webhook_secret"] != "" || entry["webhook"] != false {
+		t.Errorf("webhook = %v, webhook_secret = %v, want them unset", entry["webhook"], entry["webhook_secret"])
+	}
+}
@@ -0,0 +1,69 @@
+package web
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-manager/internal"
+	"github.com/netresearch/ldap-manager/internal/options"
+	"github.com/netresearch/ldap-manager/internal/web/templates"
+)
+
+func (a *App) aboutHandler(c *fiber.Ctx) error {
+	sess, err := a.session(c)
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	if sess.Fresh() {
+		return c.Redirect("/login")
+	}
+
+	health := a.health()
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return templates.About(templates.AboutInfo{
+		Version:         internal.FormatVersion(),
+		Uptime:          time.Since(a.started).Round(time.Second).String(),
+		Status:          health.Status,
+		Users:           health.Users,
+		Groups:          health.Groups,
+		Computers:       health.Computers,
+		CacheAgeSeconds: health.CacheAgeSeconds,
+		Warnings:        health.Warnings,
+		Settings:        a.settings,
+	}).Render(c.UserContext(), c.Response().BodyWriter())
+}
+
+// aboutSettings lists the configuration shown on the about page. Passwords
+// and secrets are only reported as set or not, and the webhook URL is left
+// out as it may carry a token.
+func aboutSettings(opts *options.Opts) []templates.Setting {
+	isSet := func(v string) string {
+		if v == "" {
+			return "not set"
+		}
+
+		return "set"
+	}
+
+	return []templates.Setting{
+		{Name: "Mode", Value: string(opts.Mode)},
+		{Name: "LDAP server", Value: opts.LDAP.Server},
+		{Name: "Base DN", Value: opts.LDAP.BaseDN},
+		{Name: "ActiveDirectory", Value: fmt.Sprint(opts.LDAP.IsActiveDirectory)},
+		{Name: "Readonly user", Value: opts.ReadonlyUser},
+		{Name: "Readonly password", Value: isSet(opts.ReadonlyPassword)},
+		{Name: "Max concurrent binds", Value: fmt.Sprint(opts.LDAPMaxConcurrentBinds)},
+		{Name: "Persist sessions", Value: fmt.Sprint(opts.PersistSessions)},
+		{Name: "Session duration", Value: opts.SessionDuration.String()},
+		{Name: "Cookie secure", Value: string(opts.CookieSecure)},
+		{Name: "Login max failures", Value: fmt.Sprint(opts.LoginMaxFailures)},
+		{Name: "Credential verification API", Value: fmt.Sprint(opts.AuthVerifyEnabled)},
+		{Name: "Request timeout", Value: opts.RequestTimeout.String()},
+		{Name: "Self-test interval", Value: opts.SelfTestInterval.String()},
+		{Name: "Webhook", Value: isSet(opts.WebhookURL)},
+		{Name: "Webhook secret", Value: isSet(opts.WebhookSecret)},
+	}
+}
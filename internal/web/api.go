@@ -0,0 +1,88 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	ldap "github.com/netresearch/simple-ldap-go"
+	"github.com/rs/zerolog/log"
+)
+
+type whoamiResponse struct {
+	DN             string `json:"dn"`
+	SAMAccountName string `json:"sam_account_name"`
+	DisplayName    string `json:"display_name"`
+	GroupCount     int    `json:"group_count"`
+}
+
+func (a *App) whoamiHandler(c *fiber.Ctx) error {
+	sess, err := a.session(c)
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	if sess.Fresh() {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "not logged in"})
+	}
+
+	user, err := a.ldapCache.FindUserByDN(sess.Get("dn").(string))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(whoamiResponse{
+		DN:             user.DN(),
+		SAMAccountName: user.SAMAccountName,
+		DisplayName:    user.CN(),
+		GroupCount:     len(user.Groups),
+	})
+}
+
+type verifyCredentialsForm struct {
+	Username string `form:"username" json:"username"`
+	Password string `form:"password" json:"password"`
+}
+
+// verifyCredentialsHandler checks a username and password without creating a
+// session, for use by reverse proxy authentication. Failed attempts count
+// against the same limit as failed logins.
+func (a *App) verifyCredentialsHandler(c *fiber.Ctx) error {
+	form := verifyCredentialsForm{}
+	if err := c.BodyParser(&form); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	keys := loginKeys(c.IP(), form.Username)
+	if !a.loginLimiter.Allowed(keys...) {
+		log.Warn().Str("user", form.Username).Str("ip", c.IP()).Msg("rejected credential verification after too many failed attempts")
+
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "too many failed attempts"})
+	}
+
+	if !credentialsGiven(form.Username, form.Password) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "username and password are required"})
+	}
+
+	var user *ldap.User
+	err := a.ldapOperation(c.UserContext(), "check password", form.Username, func() (err error) {
+		user, err = a.ldapClient.CheckPasswordForSAMAccountName(form.Username, form.Password)
+
+		return err
+	})
+	if err != nil {
+		log.Debug().Err(err).Msgf("could not verify credentials for %s", form.Username)
+
+		if isWrongPassword(err) {
+			a.loginLimiter.Fail(keys...)
+		}
+
+		message := "invalid username or password"
+		if isAccountLocked(err) {
+			message = "account is locked"
+		}
+
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": message})
+	}
+
+	a.loginLimiter.Succeed(userKey(form.Username))
+
+	return c.JSON(fiber.Map{"dn": user.DN()})
+}
@@ -0,0 +1,32 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestWhoami(t *testing.T) {
+	a, _ := newTestApp(t, nil)
+
+	res, _ := testRequest(t, a, http.MethodGet, "/api/v1/whoami", "", nil)
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("whoami without a session answered with %d, want %d", res.StatusCode, http.StatusUnauthorized)
+	}
+
+	cookie := login(t, a, "jdoe", "jdoe")
+	res, body := testRequest(t, a, http.MethodGet, "/api/v1/whoami", cookie, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("whoami answered with %d", res.StatusCode)
+	}
+
+	var got whoamiResponse
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := whoamiResponse{DN: testUserDN, SAMAccountName: "jdoe", DisplayName: "John Doe", GroupCount: 1}
+	if got != want {
+		t.Errorf("whoami = %+v, want %+v", got, want)
+	}
+}
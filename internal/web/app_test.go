@@ -0,0 +1,171 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/netresearch/ldap-manager/internal/ldaptest"
+	"github.com/netresearch/ldap-manager/internal/options"
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+// Directory of the apps created by newTestApp.
+const (
+	testBaseDN    = "dc=example,dc=com"
+	testReaderDN  = "cn=reader,dc=example,dc=com"
+	testUserDN    = "cn=John Doe,ou=users,dc=example,dc=com"
+	testOtherDN   = "cn=Jane Roe,ou=users,dc=example,dc=com"
+	testGroupDN   = "cn=admins,ou=groups,dc=example,dc=com"
+	testEmptyDN   = "cn=empty,ou=groups,dc=example,dc=com"
+	testComputeDN = "cn=DESKTOP,ou=computers,dc=example,dc=com"
+
+	testReaderPassword = "reader-secret"
+)
+
+// testOpts returns the defaults of options.Parse for an app using server.
+func testOpts(server *ldaptest.Server) *options.Opts {
+	return &options.Opts{
+		Mode:          options.ModeFull,
+		LogSampleRate: 1,
+		AppTitle:      "LDAP Manager",
+		AppLogoURL:    "/static/logo.webp",
+
+		LDAP: ldap.Config{
+			Server:            server.URL,
+			BaseDN:            testBaseDN,
+			IsActiveDirectory: true,
+		},
+		AllowInsecureBind:   true,
+		ReadonlyUser:        testReaderDN,
+		ReadonlyPassword:    testReaderPassword,
+		CacheMaxDropPercent: 50,
+		CacheIndexShards:    1,
+
+		SessionDuration:        30 * time.Minute,
+		SessionCleanupInterval: time.Hour,
+		CookieSecure:           options.CookieSecureNever,
+		LogoutOnInvalidCreds:   true,
+		LoginMaxFailures:       5,
+		LoginFailureWindow:     15 * time.Minute,
+
+		ShutdownTimeout:    30 * time.Second,
+		MaxDNLength:        1024,
+		MaxDNDepth:         32,
+		CompressionMinSize: 1024,
+
+		DefaultLanding:  options.LandingProfile,
+		HTMXPartials:    true,
+		BulkMaxUsers:    100,
+		BulkConcurrency: 4,
+		DNValidation:    options.DNValidationReject,
+	}
+}
+
+// newTestServer returns an LDAP server with the readonly user, two users who
+// can sign in with their sAMAccountName as password, a group with the first
+// user, an empty group and a computer.
+func newTestServer(t *testing.T) *ldaptest.Server {
+	t.Helper()
+
+	server := ldaptest.NewServer(t, testBaseDN)
+	server.AddUser(testReaderDN, "reader", testReaderPassword)
+	server.AddUser(testUserDN, "jdoe", "jdoe")
+	server.AddUser(testOtherDN, "jroe", "jroe")
+	server.AddGroup(testGroupDN, testUserDN)
+	server.AddGroup(testEmptyDN)
+	server.AddComputer(testComputeDN, "DESKTOP$")
+
+	return server
+}
+
+// newTestApp returns an app with a warmed up cache of a newTestServer. The
+// options can be changed with configure before the app is created.
+func newTestApp(t *testing.T, configure func(*options.Opts)) (*App, *ldaptest.Server) {
+	t.Helper()
+
+	a, server := newColdTestApp(t, configure)
+	if !a.monitorOnly {
+		if err := a.ldapCache.Refresh(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return a, server
+}
+
+// newColdTestApp returns an app of a newTestServer like newTestApp, whose
+// cache has not been filled yet.
+func newColdTestApp(t *testing.T, configure func(*options.Opts)) (*App, *ldaptest.Server) {
+	t.Helper()
+
+	server := newTestServer(t)
+	opts := testOpts(server)
+	if configure != nil {
+		configure(opts)
+	}
+
+	a, err := NewApp(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = a.sessionStorage.Close() })
+
+	return a, server
+}
+
+// testRequest sends a request to the app, with the session cookie if it is
+// not empty, and returns the response with its body.
+func testRequest(t *testing.T, a *App, method, target, cookie string, body url.Values) (*http.Response, string) {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		reader = strings.NewReader(body.Encode())
+	}
+
+	req := httptest.NewRequest(method, target, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if cookie != "" {
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: cookie})
+	}
+
+	res, err := a.fiber.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res, string(data)
+}
+
+// login signs in with the credentials and returns the session cookie.
+func login(t *testing.T, a *App, username, password string) string {
+	t.Helper()
+
+	query := url.Values{"username": {username}, "password": {password}}
+	res, _ := testRequest(t, a, http.MethodGet, "/login?"+query.Encode(), "", nil)
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("login as %s answered with %d", username, res.StatusCode)
+	}
+
+	for _, cookie := range res.Cookies() {
+		if cookie.Name == sessionCookieName {
+			return cookie.Value
+		}
+	}
+
+	t.Fatalf("login as %s did not set a session cookie", username)
+
+	return ""
+}
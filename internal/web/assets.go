@@ -0,0 +1,50 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/filesystem"
+	"github.com/netresearch/ldap-manager/internal/web/static"
+)
+
+// faviconHandler serves the favicon at the root, where browsers request it
+// regardless of the icons linked in the page.
+func (a *App) faviconHandler(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=86400")
+
+	return filesystem.SendFile(c, http.FS(static.Static), "favicon.ico")
+}
+
+type manifestIcon struct {
+	Src   string `json:"src"`
+	Sizes string `json:"sizes"`
+	Type  string `json:"type"`
+}
+
+type webAppManifest struct {
+	Name            string         `json:"name"`
+	ShortName       string         `json:"short_name"`
+	StartURL        string         `json:"start_url"`
+	Display         string         `json:"display"`
+	ThemeColor      string         `json:"theme_color"`
+	BackgroundColor string         `json:"background_color"`
+	Icons           []manifestIcon `json:"icons"`
+}
+
+// manifestHandler serves the web app manifest, named after the configured
+// title, so the tool can be installed as a PWA.
+func (a *App) manifestHandler(c *fiber.Ctx) error {
+	return c.JSON(webAppManifest{
+		Name:            a.branding.Title,
+		ShortName:       a.branding.Title,
+		StartURL:        "/",
+		Display:         "standalone",
+		ThemeColor:      "#b8e9f4",
+		BackgroundColor: "#000000",
+		Icons: []manifestIcon{
+			{Src: "/static/android-chrome-192x192.png", Sizes: "192x192", Type: "image/png"},
+			{Src: "/static/android-chrome-512x512.png", Sizes: "512x512", Type: "image/png"},
+		},
+	}, "application/manifest+json")
+}
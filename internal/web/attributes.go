@@ -0,0 +1,205 @@
+package web
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-manager/internal/web/templates"
+	"github.com/netresearch/ldap-manager/internal/webhook"
+)
+
+func (a *App) attributesEditable() bool {
+	return len(a.editableAttributes) > 0
+}
+
+// readAttributes fetches the editable attributes of an entry with the readonly
+// user. They are not part of the cache, so they are always read live.
+func (a *App) readAttributes(ctx context.Context, dn string) ([]templates.EditableAttribute, error) {
+	c, err := a.ldapClient.GetConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	withDeadline(ctx, c)
+
+	var res *goldap.SearchResult
+	err = a.ldapOperation(ctx, "read attributes", dn, func() (err error) {
+		res, err = c.Search(&goldap.SearchRequest{
+			BaseDN:       dn,
+			Scope:        goldap.ScopeBaseObject,
+			DerefAliases: goldap.NeverDerefAliases,
+			SizeLimit:    1,
+			Filter:       "(objectClass=*)",
+			Attributes:   a.editableAttributes,
+		})
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := make([]templates.EditableAttribute, len(a.editableAttributes))
+	for i, name := range a.editableAttributes {
+		attributes[i].Name = name
+		if len(res.Entries) > 0 {
+			attributes[i].Values = res.Entries[0].GetEqualFoldAttributeValues(name)
+		}
+	}
+
+	return attributes, nil
+}
+
+func (a *App) userAttributesHandler(c *fiber.Ctx) error {
+	sess, err := a.session(c)
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	if sess.Fresh() {
+		return c.Redirect("/login")
+	}
+
+	userDN, err := url.PathUnescape(c.Params("userDN"))
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	return a.renderUserAttributes(c, userDN, templates.Flashes())
+}
+
+// userAttributesModifyHandler replaces the values of the editable attributes
+// which were changed in the form. Each line of a field is one value, an empty
+// field removes the attribute.
+func (a *App) userAttributesModifyHandler(c *fiber.Ctx) error {
+	sess, err := a.session(c)
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	if sess.Fresh() {
+		return c.Redirect("/login")
+	}
+
+	userDN, err := url.PathUnescape(c.Params("userDN"))
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	if _, err := a.ldapCache.FindUserByDN(userDN); err != nil {
+		return handle500(c, err)
+	}
+
+	current, err := a.readAttributes(c.UserContext(), userDN)
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	req := goldap.NewModifyRequest(userDN, nil)
+	changed := make(map[string][]string)
+	for _, attribute := range current {
+		values := formValues(c.FormValue(attribute.Name))
+		if equalValues(values, attribute.Values) {
+			continue
+		}
+
+		req.Replace(attribute.Name, values)
+		changed[attribute.Name] = values
+	}
+
+	if len(changed) == 0 {
+		return a.renderUserAttributes(c, userDN, templates.Flashes(templates.InfoFlash("Nothing changed")))
+	}
+
+	l, err := a.sessionToLDAPClient(c.UserContext(), sess)
+	if a.credentialsRevoked(err) {
+		return a.expireSession(c, sess)
+	}
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	// The modification is not bounded by the request deadline, as a timed out
+	// write might still be applied by the server.
+	err = a.ldapOperation(c.UserContext(), "modify attributes", userDN, func() error {
+		conn, err := l.GetConnection()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		return conn.Modify(req)
+	})
+	if a.credentialsRevoked(err) {
+		return a.expireSession(c, sess)
+	}
+	if err != nil {
+		return a.renderUserAttributes(c, userDN, templates.Flashes(
+			templates.ErrorFlash("Failed to modify: "+explainLDAPError(err)),
+		))
+	}
+
+	a.ldapCache.OnModifyUserAttributes(userDN, changed)
+
+	attributes := make([]string, 0, len(changed))
+	for name := range changed {
+		attributes = append(attributes, name)
+	}
+	sort.Strings(attributes)
+
+	a.webhook.Notify(webhook.Event{
+		Operation:  webhook.OperationModifyAttributes,
+		Actor:      sess.Get("dn").(string),
+		Target:     userDN,
+		Attributes: attributes,
+		Timestamp:  time.Now(),
+	})
+
+	return a.renderUserAttributes(c, userDN, templates.Flashes(templates.SuccessFlash("Successfully modified attributes")))
+}
+
+func (a *App) renderUserAttributes(c *fiber.Ctx, userDN string, flashes []templates.Flash) error {
+	user, err := a.ldapCache.FindUserByDN(userDN)
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	attributes, err := a.readAttributes(c.UserContext(), userDN)
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return templates.UserAttributes(*user, attributes, flashes).Render(c.UserContext(), c.Response().BodyWriter())
+}
+
+// formValues splits a form field into one value per non-empty line.
+func formValues(field string) []string {
+	values := make([]string, 0)
+	for _, line := range strings.Split(field, "\n") {
+		if value := strings.TrimSpace(line); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
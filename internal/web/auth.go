@@ -2,13 +2,15 @@ package web
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
 	"github.com/netresearch/ldap-manager/internal"
 	"github.com/netresearch/ldap-manager/internal/web/templates"
+	ldap "github.com/netresearch/simple-ldap-go"
 	"github.com/rs/zerolog/log"
 )
 
 func (a *App) logoutHandler(c *fiber.Ctx) error {
-	sess, err := a.sessionStore.Get(c)
+	sess, err := a.session(c)
 	if err != nil {
 		return handle500(c, err)
 	}
@@ -21,32 +23,107 @@ func (a *App) logoutHandler(c *fiber.Ctx) error {
 }
 
 func (a *App) loginHandler(c *fiber.Ctx) error {
-	sess, err := a.sessionStore.Get(c)
+	sess, err := a.session(c)
 	if err != nil {
 		return handle500(c, err)
 	}
 
 	username := c.Query("username")
 	password := c.Query("password")
+	keys := loginKeys(c.IP(), username)
 
-	if username != "" && password != "" {
-		user, err := a.ldapClient.CheckPasswordForSAMAccountName(username, password)
+	if loginSubmitted(c) && !a.loginLimiter.Allowed(keys...) {
+		log.Warn().Str("user", username).Str("ip", c.IP()).Msg("rejected login after too many failed attempts")
+
+		c.Status(fiber.StatusTooManyRequests)
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return templates.Login(templates.Flashes(templates.ErrorFlash("Too many failed attempts, please try again later")), "").Render(c.UserContext(), c.Response().BodyWriter())
+	}
+
+	// The form was submitted with a field left empty. This is rejected before
+	// binding, as some directories accept a bind with an empty password as an
+	// unauthenticated bind, which would look like a successful login.
+	if loginSubmitted(c) && !credentialsGiven(username, password) {
+		log.Debug().Str("user", username).Msg("rejected login with empty username or password")
+
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return templates.Login(templates.Flashes(templates.ErrorFlash("Please enter your username and password")), "").Render(c.UserContext(), c.Response().BodyWriter())
+	}
+
+	if credentialsGiven(username, password) {
+		var user *ldap.User
+		err := a.ldapOperation(c.UserContext(), "check password", username, func() (err error) {
+			user, err = a.ldapClient.CheckPasswordForSAMAccountName(username, password)
+
+			return err
+		})
 		if err != nil {
 			log.Error().Err(err).Msg("could not check password")
 
+			if isWrongPassword(err) {
+				a.loginLimiter.Fail(keys...)
+			}
+
+			message := "Invalid username or password"
+			if isAccountLocked(err) {
+				message = "Your account is locked, please contact your administrator"
+			}
+
 			c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
-			return templates.Login(templates.Flashes(templates.ErrorFlash("Invalid username or password")), "").Render(c.UserContext(), c.Response().BodyWriter())
+			return templates.Login(templates.Flashes(templates.ErrorFlash(message)), "").Render(c.UserContext(), c.Response().BodyWriter())
 		}
 
+		a.loginLimiter.Succeed(userKey(username))
+
 		sess.Set("dn", user.DN())
 		sess.Set("password", password)
 		if err := sess.Save(); err != nil {
 			return handle500(c, err)
 		}
+		c.Locals(userDNKey, user.DN())
 
 		return c.Redirect("/")
 	}
 
+	flashes := templates.Flashes()
+	if c.Query("expired") != "" {
+		flashes = templates.Flashes(templates.InfoFlash("Your credentials are no longer valid, please sign in again"))
+	}
+
 	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
-	return templates.Login(templates.Flashes(), internal.FormatVersion()).Render(c.UserContext(), c.Response().BodyWriter())
+	return templates.Login(flashes, internal.FormatVersion()).Render(c.UserContext(), c.Response().BodyWriter())
+}
+
+// loginSubmitted reports whether the request comes from the login form, as
+// opposed to just opening the login page.
+func loginSubmitted(c *fiber.Ctx) bool {
+	args := c.Context().QueryArgs()
+
+	return args.Has("username") || args.Has("password")
+}
+
+// credentialsGiven reports whether both a username and a password are set.
+// Credentials must be checked with it before binding, since an empty password
+// can result in an unauthenticated bind that does not fail.
+func credentialsGiven(username, password string) bool {
+	return username != "" && password != ""
+}
+
+// credentialsRevoked reports whether err shows that the credentials stored in
+// the session are no longer accepted, e.g. because the password was changed or
+// the account was disabled after logging in, and the user should be logged out.
+func (a *App) credentialsRevoked(err error) bool {
+	return a.logoutOnInvalidCreds && isInvalidCredentials(err)
+}
+
+// expireSession logs the user out and asks them to sign in again, instead of
+// letting every further operation fail with the same error.
+func (a *App) expireSession(c *fiber.Ctx, sess *session.Session) error {
+	log.Info().Str("user", sess.Get("dn").(string)).Msg("credentials are no longer valid, logging out")
+
+	if err := sess.Destroy(); err != nil {
+		return handle500(c, err)
+	}
+
+	return c.Redirect("/login?expired=1")
 }
@@ -0,0 +1,170 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/netresearch/ldap-manager/internal/ldaptest"
+	"github.com/netresearch/ldap-manager/internal/options"
+)
+
+func TestLoginLockedAccountNotCounted(t *testing.T) {
+	a, server := newTestApp(t, func(opts *options.Opts) {
+		opts.AuthVerifyEnabled = true
+	})
+	server.Lock(testUserDN)
+
+	query := url.Values{"username": {"jdoe"}, "password": {"jdoe"}}
+	_, body := testRequest(t, a, http.MethodGet, "/login?"+query.Encode(), "", nil)
+	if !strings.Contains(body, "Your account is locked") {
+		t.Errorf("login page does not show the lock: %s", body)
+	}
+
+	res, body := testRequest(t, a, http.MethodPost, "/api/v1/auth/verify", "", query)
+	if res.StatusCode != http.StatusUnauthorized || !strings.Contains(body, "account is locked") {
+		t.Errorf("verification answered with %d: %s", res.StatusCode, body)
+	}
+
+	for _, key := range loginKeys("0.0.0.0", "jdoe") {
+		if got := failureCount(a.loginLimiter, key); got != 0 {
+			t.Errorf("%s has %d failures, want 0", key, got)
+		}
+	}
+}
+
+// loginFrom submits the login form through a proxy forwarding for client and
+// returns the response status.
+func loginFrom(t *testing.T, a *App, client, username, password string) int {
+	t.Helper()
+
+	query := url.Values{"username": {username}, "password": {password}}
+	req := httptest.NewRequest(http.MethodGet, "/login?"+query.Encode(), nil)
+	req.Header.Set("X-Forwarded-For", client)
+
+	res, err := a.fiber.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res.StatusCode
+}
+
+func TestLoginLimiterBehindProxy(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		// blocked reports whether the second client is blocked after the
+		// first one used up its failures.
+		blocked bool
+	}{
+		// Requests sent by fiber's test client come from 0.0.0.0.
+		{"trusted proxy", []string{"0.0.0.0"}, false},
+		{"untrusted proxy", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := newTestApp(t, func(opts *options.Opts) {
+				opts.TrustedProxies = tt.proxies
+				opts.LoginMaxFailures = 2
+			})
+
+			// Different usernames, so only the address is limited.
+			loginFrom(t, a, "192.0.2.1", "jdoe", "wrong")
+			loginFrom(t, a, "192.0.2.1", "jroe", "wrong")
+
+			if got := loginFrom(t, a, "192.0.2.1", "reader", testReaderPassword); got != http.StatusTooManyRequests {
+				t.Errorf("first client answered with %d, want 429", got)
+			}
+
+			want := http.StatusFound
+			if tt.blocked {
+				want = http.StatusTooManyRequests
+			}
+			if got := loginFrom(t, a, "192.0.2.2", "reader", testReaderPassword); got != want {
+				t.Errorf("second client answered with %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestLoginLimiterCountsOnlyWrongPasswords(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		result   *ldaptest.Result
+		counted  bool
+	}{
+		{"wrong password", "jdoe", "wrong", nil, true},
+		{"unknown user", "nobody", "wrong", nil, true},
+		{"empty password", "jdoe", "", nil, false},
+		{"busy server", "jdoe", "jdoe", &ldaptest.Result{Code: goldap.LDAPResultBusy, Message: "busy"}, false},
+		{"unavailable server", "jdoe", "jdoe", &ldaptest.Result{Code: goldap.LDAPResultUnavailable, Message: "unavailable"}, false},
+		{"time limit", "jdoe", "jdoe", &ldaptest.Result{Code: goldap.LDAPResultTimeLimitExceeded, Message: "timeout"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, server := newTestApp(t, func(opts *options.Opts) {
+				opts.AuthVerifyEnabled = true
+			})
+			server.Intercept(func(req ldaptest.Request) *ldaptest.Result {
+				if req.Operation == "bind" && req.DN == testUserDN {
+					return tt.result
+				}
+
+				return nil
+			})
+
+			query := url.Values{"username": {tt.username}, "password": {tt.password}}
+			testRequest(t, a, http.MethodGet, "/login?"+query.Encode(), "", nil)
+			testRequest(t, a, http.MethodPost, "/api/v1/auth/verify", "", query)
+
+			want := 0
+			if tt.counted {
+				want = 2
+			}
+			if got := failureCount(a.loginLimiter, userKey(tt.username)); got != want {
+				t.Errorf("counted %d failures, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestLoginEmptyPassword(t *testing.T) {
+	a, server := newTestApp(t, nil)
+	binds := server.Count("bind")
+
+	query := url.Values{"username": {"jdoe"}, "password": {""}}
+	res, body := testRequest(t, a, http.MethodGet, "/login?"+query.Encode(), "", nil)
+	if res.StatusCode != http.StatusOK || !strings.Contains(body, "Please enter your username and password") {
+		t.Errorf("login answered with %d: %s", res.StatusCode, body)
+	}
+
+	for _, cookie := range res.Cookies() {
+		if cookie.Name == sessionCookieName {
+			t.Errorf("login created the session %s", cookie.Value)
+		}
+	}
+
+	if got := server.Count("bind"); got != binds {
+		t.Errorf("login sent %d binds, want none", got-binds)
+	}
+}
+
+func TestNewAppDoesNotLogSecrets(t *testing.T) {
+	buf := captureLog(t)
+	newTestApp(t, nil)
+
+	if !strings.Contains(buf.String(), testReaderDN) {
+		t.Errorf("log does not name the readonly user: %s", buf.String())
+	}
+	if strings.Contains(buf.String(), testReaderPassword) {
+		t.Errorf("log contains the readonly password: %s", buf.String())
+	}
+}
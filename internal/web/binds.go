@@ -0,0 +1,90 @@
+package web
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// bindLimiter bounds the amount of simultaneous authenticated LDAP binds, so a
+// burst of logins queues up instead of tripping the directory's bind throttling.
+type bindLimiter struct {
+	slots    chan struct{}
+	inFlight atomic.Int64
+	// slowWait is the time waiting for a free slot after which a warning is
+	// logged, so a too small limit is noticed before users complain.
+	slowWait time.Duration
+}
+
+// newBindLimiter returns a limiter allowing max concurrent binds, or an
+// unlimited one if max is zero. A slowWait of zero disables the warning.
+func newBindLimiter(max int, slowWait time.Duration) *bindLimiter {
+	l := &bindLimiter{slowWait: slowWait}
+	if max > 0 {
+		l.slots = make(chan struct{}, max)
+	}
+
+	return l
+}
+
+// do runs fn once a slot is free. fn is not started when ctx is done before,
+// but once started it runs to completion, so a write is never reported as
+// failed after it was applied.
+func (l *bindLimiter) do(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if l.slots != nil {
+		if err := l.acquire(ctx); err != nil {
+			return err
+		}
+		defer func() { <-l.slots }()
+	}
+
+	l.inFlight.Add(1)
+	defer l.inFlight.Add(-1)
+
+	return fn()
+}
+
+// acquire waits for a free slot until ctx is done. The warning is logged while
+// still waiting, not only once a slot was found.
+func (l *bindLimiter) acquire(ctx context.Context) error {
+	if l.slowWait <= 0 {
+		select {
+		case l.slots <- struct{}{}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	start := time.Now()
+	t := time.NewTimer(l.slowWait)
+	defer t.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		log.Warn().Msgf("waiting for a free LDAP bind slot for %s, %d of %d slots in use", l.slowWait, len(l.slots), cap(l.slots))
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		log.Warn().Msgf("got a free LDAP bind slot after %s", time.Since(start).Round(time.Millisecond))
+
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *bindLimiter) InFlight() int64 {
+	return l.inFlight.Load()
+}
@@ -0,0 +1,140 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBindLimiterBoundsConcurrency(t *testing.T) {
+	const max = 3
+	l := newBindLimiter(max, 0)
+
+	var running, peak atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 4*max; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := l.do(context.Background(), func() error {
+				n := running.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				running.Add(-1)
+
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got != max {
+		t.Errorf("%d binds ran at once, want %d", got, max)
+	}
+	if got := l.InFlight(); got != 0 {
+		t.Errorf("%d binds in flight after all finished", got)
+	}
+}
+
+func TestBindLimiterWaitsForSlot(t *testing.T) {
+	l := newBindLimiter(1, 0)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		_ = l.do(context.Background(), func() error {
+			close(started)
+			<-release
+
+			return nil
+		})
+	}()
+	<-started
+
+	if got := l.InFlight(); got != 1 {
+		t.Errorf("%d binds in flight, want 1", got)
+	}
+
+	// The second bind fails with the error of its context while the slot is
+	// held, without being started.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	var ran bool
+	err := l.do(ctx, func() error {
+		ran = true
+
+		return nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("waiting bind returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	if ran {
+		t.Error("bind was started without a free slot")
+	}
+
+	// Once the slot is freed, a waiting bind runs.
+	done := make(chan error)
+	go func() {
+		done <- l.do(context.Background(), func() error {
+			ran = true
+
+			return nil
+		})
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("bind ran while the slot was held")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !ran {
+		t.Error("bind did not run after the slot was freed")
+	}
+}
+
+func TestBindLimiterUnlimited(t *testing.T) {
+	l := newBindLimiter(0, 0)
+
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = l.do(context.Background(), func() error {
+				<-release
+
+				return nil
+			})
+		}()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for l.InFlight() != 10 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of 10 binds in flight without a limit", l.InFlight())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	wg.Wait()
+}
@@ -0,0 +1,21 @@
+package web
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const headerCacheAge = "X-Cache-Age"
+
+// cacheAge sets the X-Cache-Age header to the seconds since the last
+// successful cache refresh, so clients can tell how fresh the data is.
+// The header is omitted until the cache has been filled once.
+func (a *App) cacheAge(c *fiber.Ctx) error {
+	if lastRefresh := a.ldapCache.LastRefresh(); !lastRefresh.IsZero() {
+		c.Set(headerCacheAge, strconv.FormatInt(int64(time.Since(lastRefresh).Seconds()), 10))
+	}
+
+	return c.Next()
+}
@@ -0,0 +1,43 @@
+package web
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/netresearch/ldap-manager/internal/options"
+)
+
+func TestCacheAgeHeader(t *testing.T) {
+	a, _ := newTestApp(t, nil)
+	cookie := login(t, a, "jdoe", "jdoe")
+
+	res, _ := testRequest(t, a, http.MethodGet, "/api/v1/whoami", cookie, nil)
+	if got := res.Header.Get(headerCacheAge); got != "0" {
+		t.Errorf("%s = %q right after the refresh, want 0", headerCacheAge, got)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	res, _ = testRequest(t, a, http.MethodGet, "/api/v1/whoami", cookie, nil)
+	if got := res.Header.Get(headerCacheAge); got != "1" {
+		t.Errorf("%s = %q a second after the refresh, want 1", headerCacheAge, got)
+	}
+
+	res, _ = testRequest(t, a, http.MethodGet, "/users", cookie, nil)
+	if got := res.Header.Get(headerCacheAge); got != "" {
+		t.Errorf("HTML page has %s %q, want none", headerCacheAge, got)
+	}
+}
+
+func TestCacheAgeHeaderHTML(t *testing.T) {
+	a, _ := newTestApp(t, func(opts *options.Opts) {
+		opts.CacheAgeHeaderHTML = true
+	})
+	cookie := login(t, a, "jdoe", "jdoe")
+
+	res, _ := testRequest(t, a, http.MethodGet, "/users", cookie, nil)
+	if got := res.Header.Get(headerCacheAge); got != "0" {
+		t.Errorf("%s = %q on an HTML page, want 0", headerCacheAge, got)
+	}
+}
@@ -0,0 +1,97 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-manager/internal/ldap_cache"
+	"github.com/netresearch/ldap-manager/internal/web/templates"
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+// compareUsers looks up the users given by the "a" and "b" query parameters,
+// each either a DN or a sAMAccountName, and compares their group memberships.
+func (a *App) compareUsers(c *fiber.Ctx) (*ldap_cache.MembershipComparison, error) {
+	var users [2]*ldap.User
+	for i, param := range []string{"a", "b"} {
+		user, err := a.resolveUser(c.Query(param))
+		if err != nil {
+			return nil, err
+		}
+
+		users[i] = user
+	}
+
+	return a.ldapCache.CompareMemberships(users[0], users[1]), nil
+}
+
+func (a *App) usersCompareHandler(c *fiber.Ctx) error {
+	sess, err := a.session(c)
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	if sess.Fresh() {
+		return c.Redirect("/login")
+	}
+
+	form := templates.CompareForm{A: c.Query("a"), B: c.Query("b")}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	if form.A == "" || form.B == "" {
+		return templates.UsersComparison(form, nil, templates.Flashes()).Render(c.UserContext(), c.Response().BodyWriter())
+	}
+
+	cmp, err := a.compareUsers(c)
+	if err != nil {
+		c.Status(fiber.StatusNotFound)
+		return templates.UsersComparison(form, nil, templates.Flashes(
+			templates.ErrorFlash("Could not compare: "+err.Error()),
+		)).Render(c.UserContext(), c.Response().BodyWriter())
+	}
+
+	return templates.UsersComparison(form, cmp, templates.Flashes()).Render(c.UserContext(), c.Response().BodyWriter())
+}
+
+type membershipComparisonResponse struct {
+	A      string   `json:"a"`
+	B      string   `json:"b"`
+	Common []string `json:"common"`
+	OnlyA  []string `json:"only_a"`
+	OnlyB  []string `json:"only_b"`
+}
+
+func groupDNs(groups []ldap.Group) []string {
+	dns := make([]string, len(groups))
+	for i, group := range groups {
+		dns[i] = group.DN()
+	}
+
+	return dns
+}
+
+func (a *App) usersCompareAPIHandler(c *fiber.Ctx) error {
+	sess, err := a.session(c)
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	if sess.Fresh() {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "not logged in"})
+	}
+
+	if c.Query("a") == "" || c.Query("b") == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "the query parameters a and b are required"})
+	}
+
+	cmp, err := a.compareUsers(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(membershipComparisonResponse{
+		A:      cmp.A.DN(),
+		B:      cmp.B.DN(),
+		Common: groupDNs(cmp.Common),
+		OnlyA:  groupDNs(cmp.OnlyA),
+		OnlyB:  groupDNs(cmp.OnlyB),
+	})
+}
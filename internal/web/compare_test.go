@@ -0,0 +1,82 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestUsersCompareAPI(t *testing.T) {
+	a, server := newTestApp(t, nil)
+
+	// Both users are members of one group, each of another one.
+	const (
+		commonDN = "cn=common,ou=groups,dc=example,dc=com"
+		otherDN  = "cn=other,ou=groups,dc=example,dc=com"
+	)
+	server.AddGroup(commonDN, testUserDN, testOtherDN)
+	server.AddGroup(otherDN, testOtherDN)
+	if err := a.ldapCache.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	cookie := login(t, a, "jdoe", "jdoe")
+
+	// The users can be given by DN or by sAMAccountName.
+	query := url.Values{"a": {testUserDN}, "b": {"jroe"}}
+	res, body := testRequest(t, a, http.MethodGet, "/api/v1/users/compare?"+query.Encode(), cookie, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("comparison answered with %d: %s", res.StatusCode, body)
+	}
+
+	var cmp membershipComparisonResponse
+	if err := json.Unmarshal([]byte(body), &cmp); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmp.A != testUserDN || cmp.B != testOtherDN {
+		t.Errorf("compared %q and %q, want %q and %q", cmp.A, cmp.B, testUserDN, testOtherDN)
+	}
+	if !slices.Equal(cmp.Common, []string{commonDN}) {
+		t.Errorf("common groups = %v, want %v", cmp.Common, []string{commonDN})
+	}
+	if !slices.Equal(cmp.OnlyA, []string{testGroupDN}) {
+		t.Errorf("groups only of a = %v, want %v", cmp.OnlyA, []string{testGroupDN})
+	}
+	if !slices.Equal(cmp.OnlyB, []string{otherDN}) {
+		t.Errorf("groups only of b = %v, want %v", cmp.OnlyB, []string{otherDN})
+	}
+}
+
+func TestUsersCompareUnknownUser(t *testing.T) {
+	a, _ := newTestApp(t, nil)
+	cookie := login(t, a, "jdoe", "jdoe")
+
+	query := url.Values{"a": {testUserDN}, "b": {"cn=nobody,ou=users,dc=example,dc=com"}}
+
+	res, _ := testRequest(t, a, http.MethodGet, "/api/v1/users/compare?"+query.Encode(), cookie, nil)
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("API comparison with an unknown user answered with %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+
+	res, body := testRequest(t, a, http.MethodGet, "/users/compare?"+query.Encode(), cookie, nil)
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("comparison with an unknown user answered with %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+	if !strings.Contains(body, "Could not compare") {
+		t.Error("comparison with an unknown user does not show an error")
+	}
+}
+
+func TestUsersCompareAPIRequiresLogin(t *testing.T) {
+	a, _ := newTestApp(t, nil)
+
+	query := url.Values{"a": {testUserDN}, "b": {testOtherDN}}
+	res, _ := testRequest(t, a, http.MethodGet, "/api/v1/users/compare?"+query.Encode(), "", nil)
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("comparison without a session answered with %d, want %d", res.StatusCode, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,36 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// compressAbove compresses responses like the compress middleware at its best
+// speed level, but leaves responses smaller than minSize bytes alone, where
+// compression costs more CPU than it saves and can even grow the payload.
+// Streamed responses of unknown size are always compressed.
+func compressAbove(minSize int) fiber.Handler {
+	compressor := fasthttp.CompressHandlerBrotliLevel(func(*fasthttp.RequestCtx) {},
+		fasthttp.CompressBrotliBestSpeed,
+		fasthttp.CompressBestSpeed,
+	)
+
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		size := len(c.Response().Body())
+		if c.Response().IsBodyStream() {
+			size = c.Response().Header.ContentLength()
+		}
+
+		if size >= 0 && size < minSize {
+			return nil
+		}
+
+		compressor(c.Context())
+
+		return nil
+	}
+}
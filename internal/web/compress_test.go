@@ -0,0 +1,58 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCompressAbove(t *testing.T) {
+	const minSize = 1024
+
+	f := fiber.New()
+	f.Use(compressAbove(minSize))
+	f.Get("/:size<int>", func(c *fiber.Ctx) error {
+		size, err := c.ParamsInt("size")
+		if err != nil {
+			return err
+		}
+
+		return c.SendString(strings.Repeat("a", size))
+	})
+
+	tests := []struct {
+		name     string
+		size     int
+		encoding string
+		want     string
+	}{
+		{"small", 100, "gzip", ""},
+		{"just below the minimum", minSize - 1, "gzip", ""},
+		{"at the minimum", minSize, "gzip", "gzip"},
+		{"large", 10 * minSize, "gzip", "gzip"},
+		{"large with brotli", 10 * minSize, "br", "br"},
+		{"large without encodings", 10 * minSize, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/"+strconv.Itoa(tt.size), nil)
+			if tt.encoding != "" {
+				req.Header.Set("Accept-Encoding", tt.encoding)
+			}
+
+			res, err := f.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := res.Header.Get("Content-Encoding"); got != tt.want {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,13 +3,16 @@ package web
 import (
 	"net/url"
 	"sort"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-manager/internal/ldap_cache"
 	"github.com/netresearch/ldap-manager/internal/web/templates"
+	ldap "github.com/netresearch/simple-ldap-go"
 )
 
 func (a *App) computersHandler(c *fiber.Ctx) error {
-	sess, err := a.sessionStore.Get(c)
+	sess, err := a.session(c)
 	if err != nil {
 		return handle500(c, err)
 	}
@@ -18,18 +21,18 @@ func (a *App) computersHandler(c *fiber.Ctx) error {
 		return c.Redirect("/login")
 	}
 
-	showDisabled := c.Query("show-disabled", "0") == "1"
+	showDisabled := a.showDisabled(c, sess)
 	computers := a.ldapCache.FindComputers(showDisabled)
-	sort.SliceStable(computers, func(i, j int) bool {
-		return computers[i].CN() < computers[j].CN()
-	})
+	if order, ok := sortOrder(c); ok {
+		ldap_cache.SortComputers(computers, order)
+	}
 
 	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
-	return templates.Computers(computers).Render(c.UserContext(), c.Response().BodyWriter())
+	return templates.Computers(computers, emptyState(len(computers), a.ldapCache.Computers.Count())).Render(c.UserContext(), c.Response().BodyWriter())
 }
 
 func (a *App) computerHandler(c *fiber.Ctx) error {
-	sess, err := a.sessionStore.Get(c)
+	sess, err := a.session(c)
 	if err != nil {
 		return handle500(c, err)
 	}
@@ -43,7 +46,7 @@ func (a *App) computerHandler(c *fiber.Ctx) error {
 		return handle500(c, err)
 	}
 
-	thinComputer, err := a.ldapCache.FindComputerByDN(computerDN)
+	thinComputer, err := a.findComputer(computerDN)
 	if err != nil {
 		return handle500(c, err)
 	}
@@ -56,3 +59,18 @@ func (a *App) computerHandler(c *fiber.Ctx) error {
 	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
 	return templates.Computer(computer).Render(c.UserContext(), c.Response().BodyWriter())
 }
+
+// findComputer looks up a computer by DN, or by its name if the parameter is
+// not a DN, so computers can be linked to as /computers/<name>. The trailing
+// "$" of the sAMAccountName is added when the name lacks it.
+func (a *App) findComputer(computer string) (*ldap.Computer, error) {
+	if strings.Contains(computer, "=") {
+		return a.ldapCache.FindComputerByDN(computer)
+	}
+
+	if !strings.HasSuffix(computer, "$") {
+		computer += "$"
+	}
+
+	return a.ldapCache.FindComputerBySAMAccountName(computer)
+}
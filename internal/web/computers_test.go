@@ -0,0 +1,37 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestComputerByName(t *testing.T) {
+	a, _ := newTestApp(t, nil)
+	cookie := login(t, a, "jdoe", "jdoe")
+
+	for _, name := range []string{"DESKTOP", "DESKTOP$", testComputeDN} {
+		t.Run(name, func(t *testing.T) {
+			computer, err := a.findComputer(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if computer.DN() != testComputeDN {
+				t.Errorf("findComputer(%q) = %q, want %q", name, computer.DN(), testComputeDN)
+			}
+
+			res, body := testRequest(t, a, http.MethodGet, "/computers/"+url.PathEscape(name), cookie, nil)
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("computer page answered with %d", res.StatusCode)
+			}
+			if !strings.Contains(body, "DESKTOP") || strings.Contains(body, "An error occurred") {
+				t.Errorf("computer page does not show the computer: %s", body)
+			}
+		})
+	}
+
+	if _, err := a.findComputer("LAPTOP"); err == nil {
+		t.Error("found a computer that does not exist")
+	}
+}
@@ -0,0 +1,155 @@
+package web
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-manager/internal/web/templates"
+	"github.com/netresearch/ldap-manager/internal/webhook"
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+type userCopyFromForm struct {
+	// Source is the DN or sAMAccountName of the user whose memberships are copied.
+	Source string `form:"source"`
+	// Prune removes the user from groups the source user is not a member of.
+	Prune  bool `form:"prune"`
+	DryRun bool `form:"dryrun"`
+}
+
+// membershipChange is a single group membership to add or remove.
+type membershipChange struct {
+	group  ldap.Group
+	remove bool
+}
+
+// userCopyFromHandler adds a user to all groups another user is a member of.
+// There is no separate admin role, the changes are made with the credentials
+// of the logged in user, so the directory decides what they may change.
+func (a *App) userCopyFromHandler(c *fiber.Ctx) error {
+	sess, err := a.session(c)
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	if sess.Fresh() {
+		return c.Redirect("/login")
+	}
+
+	userDN, err := url.PathUnescape(c.Params("userDN"))
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	form := userCopyFromForm{}
+	if err := c.BodyParser(&form); err != nil {
+		return handle500(c, err)
+	}
+
+	target, err := a.ldapCache.FindUserByDN(userDN)
+	if err != nil {
+		c.Status(fiber.StatusNotFound)
+		return a.fourOhFourHandler(c)
+	}
+
+	source, err := a.resolveUser(strings.TrimSpace(form.Source))
+	if err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return a.renderUser(c, userDN, templates.Flashes(
+			templates.ErrorFlash("Failed to copy memberships: "+err.Error()),
+		))
+	}
+
+	cmp := a.ldapCache.CompareMemberships(target, source)
+	changes := make([]membershipChange, 0, len(cmp.OnlyB)+len(cmp.OnlyA))
+	for _, group := range cmp.OnlyB {
+		changes = append(changes, membershipChange{group: group})
+	}
+	if form.Prune {
+		for _, group := range cmp.OnlyA {
+			changes = append(changes, membershipChange{group: group, remove: true})
+		}
+	}
+
+	if len(changes) == 0 {
+		return a.renderUser(c, userDN, templates.Flashes(
+			templates.InfoFlash("The memberships already match "+source.CN()),
+		))
+	}
+
+	if form.DryRun {
+		flashes := templates.Flashes()
+		for _, change := range changes {
+			flashes = append(flashes, templates.InfoFlash("Would "+change.describe()))
+		}
+
+		return a.renderUser(c, userDN, flashes)
+	}
+
+	l, err := a.sessionToLDAPClient(c.UserContext(), sess)
+	if a.credentialsRevoked(err) {
+		return a.expireSession(c, sess)
+	}
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	// The workers must not touch c, so the context is taken beforehand.
+	ctx := c.UserContext()
+	errs := a.bulk(len(changes), func(i int) error {
+		if changes[i].remove {
+			return a.removeUserFromGroup(ctx, l, userDN, changes[i].group.DN())
+		}
+
+		return a.addUserToGroup(ctx, l, userDN, changes[i].group.DN())
+	})
+
+	actor := sess.Get("dn").(string)
+	applied := 0
+	revoked := false
+	flashes := templates.Flashes()
+
+	for i, change := range changes {
+		if err := errs[i]; err != nil {
+			revoked = revoked || a.credentialsRevoked(err)
+			flashes = append(flashes, templates.ErrorFlash(fmt.Sprintf("Failed to %s: %s", change.describe(), explainLDAPError(err))))
+
+			continue
+		}
+
+		applied++
+		operation := webhook.OperationAddUserToGroup
+		if change.remove {
+			operation = webhook.OperationRemoveUserFromGroup
+		}
+
+		a.webhook.Notify(webhook.Event{
+			Operation: operation,
+			Actor:     actor,
+			Target:    userDN,
+			Group:     change.group.DN(),
+			Timestamp: time.Now(),
+		})
+	}
+
+	if revoked {
+		return a.expireSession(c, sess)
+	}
+
+	if applied > 0 {
+		flashes = append(templates.Flashes(templates.SuccessFlash(fmt.Sprintf("Successfully applied %d of %d membership changes from %s", applied, len(changes), source.CN()))), flashes...)
+	}
+
+	return a.renderUser(c, userDN, flashes)
+}
+
+func (m membershipChange) describe() string {
+	if m.remove {
+		return "remove from " + m.group.CN()
+	}
+
+	return "add to " + m.group.CN()
+}
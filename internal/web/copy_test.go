@@ -0,0 +1,84 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestUserCopyFrom(t *testing.T) {
+	const otherGroupDN = "cn=other,ou=groups,dc=example,dc=com"
+
+	tests := []struct {
+		name   string
+		form   url.Values
+		member bool // of testGroupDN
+		pruned bool // from otherGroupDN
+		writes int
+	}{
+		{"copy", url.Values{"source": {"jdoe"}}, true, false, 1},
+		{"copy and prune", url.Values{"source": {testUserDN}, "prune": {"true"}}, true, true, 2},
+		{"dry run", url.Values{"source": {"jdoe"}, "prune": {"true"}, "dryrun": {"true"}}, false, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, server := newTestApp(t, nil)
+			server.AddGroup(otherGroupDN, testOtherDN)
+			if err := a.ldapCache.Refresh(); err != nil {
+				t.Fatal(err)
+			}
+
+			cookie := login(t, a, "jdoe", "jdoe")
+			res, body := testRequest(t, a, http.MethodPost, "/users/"+url.PathEscape(testOtherDN)+"/copy-from", cookie, tt.form)
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("copy answered with %d: %s", res.StatusCode, body)
+			}
+
+			if got := server.Count("modify"); got != tt.writes {
+				t.Errorf("sent %d modifications, want %d", got, tt.writes)
+			}
+
+			if got := slices.Contains(server.Values(testGroupDN, "member"), testOtherDN); got != tt.member {
+				t.Errorf("user is a member of the copied group: %v, want %v", got, tt.member)
+			}
+			if got := !slices.Contains(server.Values(otherGroupDN, "member"), testOtherDN); got != tt.pruned {
+				t.Errorf("user was removed from the group the source is not a member of: %v, want %v", got, tt.pruned)
+			}
+
+			// The cache follows the directory.
+			user, err := a.ldapCache.FindUserByDN(testOtherDN)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := slices.Contains(user.Groups, testGroupDN); got != tt.member {
+				t.Errorf("cached user is a member of the copied group: %v, want %v", got, tt.member)
+			}
+			if got := !slices.Contains(user.Groups, otherGroupDN); got != tt.pruned {
+				t.Errorf("cached user was removed from the other group: %v, want %v", got, tt.pruned)
+			}
+		})
+	}
+}
+
+func TestUserCopyFromUnknownUser(t *testing.T) {
+	a, server := newTestApp(t, nil)
+	cookie := login(t, a, "jdoe", "jdoe")
+
+	const unknownDN = "cn=nobody,ou=users,dc=example,dc=com"
+
+	res, _ := testRequest(t, a, http.MethodPost, "/users/"+url.PathEscape(testOtherDN)+"/copy-from", cookie, url.Values{"source": {unknownDN}})
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("copy from an unknown user answered with %d, want %d", res.StatusCode, http.StatusBadRequest)
+	}
+
+	res, _ = testRequest(t, a, http.MethodPost, "/users/"+url.PathEscape(unknownDN)+"/copy-from", cookie, url.Values{"source": {"jdoe"}})
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("copy to an unknown user answered with %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+
+	if got := server.Count("modify"); got != 0 {
+		t.Errorf("sent %d modifications for unknown users", got)
+	}
+}
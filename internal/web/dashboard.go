@@ -0,0 +1,63 @@
+package web
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-manager/internal/web/templates"
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+// dashboardCacheTTL is how long the rendered dashboard is served to everyone
+// before it is rendered again. Filtering all users for the counts on every
+// request is noticeable in large directories.
+const dashboardCacheTTL = 5 * time.Second
+
+func (a *App) dashboardHandler(c *fiber.Ctx) error {
+	sess, err := a.session(c)
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	if sess.Fresh() {
+		return c.Redirect("/login")
+	}
+
+	// HTMX requests get the page without the layout.
+	key := "page"
+	if templates.IsPartial(c.UserContext()) {
+		key = "partial"
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	if body, cached := a.dashboardCache.get(key); cached {
+		return c.Send(body)
+	}
+
+	// Everything comes from the cache, so the dashboard causes no LDAP load.
+	users := a.ldapCache.Users.Snapshot()
+	enabledUsers := len(users.Filter(func(u ldap.User) bool {
+		return u.Enabled
+	}))
+	health := a.health()
+
+	var buf bytes.Buffer
+	err = templates.Dashboard(templates.DashboardInfo{
+		EnabledUsers:    enabledUsers,
+		DisabledUsers:   users.Count() - enabledUsers,
+		Groups:          health.Groups,
+		Computers:       health.Computers,
+		Status:          health.Status,
+		CacheAgeSeconds: health.CacheAgeSeconds,
+		Warnings:        health.Warnings,
+		RecentChanges:   a.recentChanges.Get(),
+	}).Render(c.UserContext(), &buf)
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	a.dashboardCache.set(key, buf.Bytes())
+
+	return c.Send(buf.Bytes())
+}
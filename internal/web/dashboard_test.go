@@ -0,0 +1,69 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/netresearch/ldap-manager/internal/ldaptest"
+)
+
+// dashboardCount is the rendered count of label.
+func dashboardCount(count int, label string) string {
+	return fmt.Sprintf(`<p class="text-3xl">%d</p><p class="text-gray-500">%s</p>`, count, label)
+}
+
+func TestDashboard(t *testing.T) {
+	a, server := newTestApp(t, nil)
+	cookie := login(t, a, "jdoe", "jdoe")
+
+	server.Add(ldaptest.Entry{DN: "cn=Former,ou=users,dc=example,dc=com", Attributes: map[string][]string{
+		"objectClass":        {"top", "person", "organizationalPerson", "user"},
+		"cn":                 {"Former"},
+		"sAMAccountName":     {"former"},
+		"userAccountControl": {"514"},
+	}})
+	if err := a.ldapCache.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	res, body := testRequest(t, a, http.MethodGet, "/dashboard", cookie, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("dashboard answered with %d", res.StatusCode)
+	}
+
+	// Computers are users as well in ActiveDirectory.
+	enabled := len(a.ldapCache.FindUsers(false))
+	if enabled != 4 || a.ldapCache.Users.Count() != 5 {
+		t.Fatalf("cache has %d enabled of %d users, want 4 of 5", enabled, a.ldapCache.Users.Count())
+	}
+
+	wantCounts := []string{
+		dashboardCount(enabled, "Enabled users"),
+		dashboardCount(a.ldapCache.Users.Count()-enabled, "Disabled users"),
+		dashboardCount(a.ldapCache.Groups.Count(), "Groups"),
+		dashboardCount(a.ldapCache.Computers.Count(), "Computers"),
+	}
+
+	for _, want := range wantCounts {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard does not contain %s", want)
+		}
+	}
+
+	// The rendered dashboard is cached briefly and causes no LDAP load.
+	server.AddGroup("cn=new,ou=groups,dc=example,dc=com")
+	if err := a.ldapCache.RefreshGroups(); err != nil {
+		t.Fatal(err)
+	}
+	searches := server.Count("search")
+
+	_, body = testRequest(t, a, http.MethodGet, "/dashboard", cookie, nil)
+	if !strings.Contains(body, dashboardCount(2, "Groups")) {
+		t.Error("dashboard was rendered again within the cache TTL")
+	}
+	if got := server.Count("search") - searches; got != 0 {
+		t.Errorf("dashboard sent %d searches", got)
+	}
+}
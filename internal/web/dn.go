@@ -0,0 +1,69 @@
+package web
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-manager/internal/web/templates"
+	"github.com/rs/zerolog/log"
+)
+
+// dnGuard returns a factory for handlers which reject the request with 400
+// when the DN in the given route parameter exceeds maxLength bytes or
+// maxDepth RDN components, before any lookup is done for it.
+func dnGuard(maxLength, maxDepth int) func(param string) fiber.Handler {
+	return func(param string) fiber.Handler {
+		return func(c *fiber.Ctx) error {
+			dn, err := url.PathUnescape(c.Params(param))
+			if err != nil {
+				return handle500(c, err)
+			}
+
+			if err := checkDN(dn, maxLength, maxDepth); err != nil {
+				log.Warn().Err(err).Str("path", c.Path()).Msg("rejected oversized DN")
+
+				c.Status(fiber.StatusBadRequest)
+				c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+				return templates.FiveHundred(err).Render(c.UserContext(), c.Response().BodyWriter())
+			}
+
+			return c.Next()
+		}
+	}
+}
+
+func checkDN(dn string, maxLength, maxDepth int) error {
+	if len(dn) > maxLength {
+		return fmt.Errorf("the DN is %d characters long, at most %d are allowed", len(dn), maxLength)
+	}
+
+	if depth := dnDepth(dn); depth > maxDepth {
+		return fmt.Errorf("the DN has %d components, at most %d are allowed", depth, maxDepth)
+	}
+
+	return nil
+}
+
+// dnDepth counts the RDN components of dn, ignoring commas escaped with a
+// backslash.
+func dnDepth(dn string) int {
+	if dn == "" {
+		return 0
+	}
+
+	depth := 1
+	escaped := false
+	for _, r := range dn {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ',':
+			depth++
+		}
+	}
+
+	return depth
+}
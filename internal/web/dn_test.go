@@ -0,0 +1,51 @@
+package web
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDNDepth(t *testing.T) {
+	tests := []struct {
+		dn   string
+		want int
+	}{
+		{"", 0},
+		{"dc=com", 1},
+		{"cn=John Doe,ou=Users,dc=example,dc=com", 4},
+		{`cn=Doe\, John,ou=Users,dc=example,dc=com`, 4},
+		{`cn=Back\\slash,dc=com`, 2},
+		{`cn=Trailing\\,dc=com`, 2},
+		{`cn=Escaped\\\,Comma,dc=com`, 2},
+		{"cn=Ünïcödé,dc=com", 2},
+	}
+
+	for _, tt := range tests {
+		if got := dnDepth(tt.dn); got != tt.want {
+			t.Errorf("dnDepth(%q) = %d, want %d", tt.dn, got, tt.want)
+		}
+	}
+}
+
+func TestCheckDN(t *testing.T) {
+	tests := []struct {
+		name    string
+		dn      string
+		wantErr bool
+	}{
+		{"within limits", "cn=a,dc=example,dc=com", false},
+		{"at the length limit", "cn=" + strings.Repeat("a", 29), false},
+		{"too long", "cn=" + strings.Repeat("a", 30), true},
+		{"at the depth limit", "cn=a,ou=b,dc=c,dc=d", false},
+		{"too deep", "cn=a,ou=b,ou=c,dc=d,dc=e", true},
+		{"escaped commas do not count", `cn=a\,b\,c\,d,dc=e`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkDN(tt.dn, 32, 4); (err != nil) != tt.wantErr {
+				t.Errorf("checkDN() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
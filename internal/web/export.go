@@ -0,0 +1,32 @@
+package web
+
+import (
+	"bufio"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// exportLDIFHandler streams the cached users, groups and computers as LDIF.
+func (a *App) exportLDIFHandler(c *fiber.Ctx) error {
+	sess, err := a.session(c)
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	if sess.Fresh() {
+		return c.Redirect("/login")
+	}
+
+	c.Set(fiber.HeaderContentType, "text/x-ldif; charset=utf-8")
+	c.Attachment("ldap-manager.ldif")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The status is already sent once streaming starts, so errors can
+		// only be logged.
+		if err := a.ldapCache.ExportLDIF(w, a.ldifExportAttributes); err != nil {
+			log.Error().Err(err).Msg("could not export LDIF")
+		}
+	})
+
+	return nil
+}
@@ -1,17 +1,22 @@
 package web
 
 import (
+	"fmt"
 	"net/url"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/netresearch/ldap-manager/internal/ldap_cache"
 	"github.com/netresearch/ldap-manager/internal/web/templates"
+	"github.com/netresearch/ldap-manager/internal/webhook"
 	ldap "github.com/netresearch/simple-ldap-go"
 )
 
 func (a *App) groupsHandler(c *fiber.Ctx) error {
-	sess, err := a.sessionStore.Get(c)
+	sess, err := a.session(c)
 	if err != nil {
 		return handle500(c, err)
 	}
@@ -21,16 +26,16 @@ func (a *App) groupsHandler(c *fiber.Ctx) error {
 	}
 
 	groups := a.ldapCache.FindGroups()
-	sort.SliceStable(groups, func(i, j int) bool {
-		return groups[i].CN() < groups[j].CN()
-	})
+	if order, ok := sortOrder(c); ok {
+		ldap_cache.SortGroups(groups, order)
+	}
 
 	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
-	return templates.Groups(groups).Render(c.UserContext(), c.Response().BodyWriter())
+	return templates.Groups(groups, emptyState(len(groups), len(groups))).Render(c.UserContext(), c.Response().BodyWriter())
 }
 
 func (a *App) groupHandler(c *fiber.Ctx) error {
-	sess, err := a.sessionStore.Get(c)
+	sess, err := a.session(c)
 	if err != nil {
 		return handle500(c, err)
 	}
@@ -49,7 +54,7 @@ func (a *App) groupHandler(c *fiber.Ctx) error {
 		return handle500(c, err)
 	}
 
-	showDisabledUsers := c.Query("show-disabled", "0") == "1"
+	showDisabledUsers := showDisabledMembers(c)
 	group := a.ldapCache.PopulateUsersForGroup(thinGroup, showDisabledUsers)
 	sort.SliceStable(group.Members, func(i, j int) bool {
 		return group.Members[i].CN() < group.Members[j].CN()
@@ -69,7 +74,7 @@ type groupModifyForm struct {
 }
 
 func (a *App) groupModifyHandler(c *fiber.Ctx) error {
-	sess, err := a.sessionStore.Get(c)
+	sess, err := a.session(c)
 	if err != nil {
 		return handle500(c, err)
 	}
@@ -92,7 +97,10 @@ func (a *App) groupModifyHandler(c *fiber.Ctx) error {
 		return c.Redirect("/groups/" + groupDN)
 	}
 
-	l, err := a.sessionToLDAPClient(sess)
+	l, err := a.sessionToLDAPClient(c.UserContext(), sess)
+	if a.credentialsRevoked(err) {
+		return a.expireSession(c, sess)
+	}
 	if err != nil {
 		return handle500(c, err)
 	}
@@ -102,7 +110,7 @@ func (a *App) groupModifyHandler(c *fiber.Ctx) error {
 		return handle500(c, err)
 	}
 
-	showDisabledUsers := c.Query("show-disabled", "0") == "1"
+	showDisabledUsers := showDisabledMembers(c)
 	group := a.ldapCache.PopulateUsersForGroup(thinGroup, showDisabledUsers)
 	sort.SliceStable(group.Members, func(i, j int) bool {
 		return group.Members[i].CN() < group.Members[j].CN()
@@ -112,28 +120,63 @@ func (a *App) groupModifyHandler(c *fiber.Ctx) error {
 		return unassignedUsers[i].CN() < unassignedUsers[j].CN()
 	})
 
+	memberDN := form.AddUser
+	if memberDN == nil {
+		memberDN = form.RemoveUser
+	}
+
+	if err := a.validateDNs(a.findUser, *memberDN); err != nil {
+		c.Status(fiber.StatusBadRequest)
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return templates.Group(
+			group, unassignedUsers, templates.Flashes(
+				templates.ErrorFlash("Failed to modify: "+err.Error()),
+			),
+		).Render(c.UserContext(), c.Response().BodyWriter())
+	}
+
 	if form.AddUser != nil {
-		if err := l.AddUserToGroup(*form.AddUser, thinGroup.DN()); err != nil {
+		if err := a.addUserToGroup(c.UserContext(), l, *form.AddUser, thinGroup.DN()); err != nil {
+			if a.credentialsRevoked(err) {
+				return a.expireSession(c, sess)
+			}
+
 			c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
 			return templates.Group(
 				group, unassignedUsers, templates.Flashes(
-					templates.ErrorFlash("Failed to modify: "+err.Error()),
+					templates.ErrorFlash("Failed to modify: "+explainLDAPError(err)),
 				),
 			).Render(c.UserContext(), c.Response().BodyWriter())
 		}
 
-		a.ldapCache.OnAddUserToGroup(*form.AddUser, thinGroup.DN())
+		a.webhook.Notify(webhook.Event{
+			Operation: webhook.OperationAddUserToGroup,
+			Actor:     sess.Get("dn").(string),
+			Target:    *form.AddUser,
+			Group:     thinGroup.DN(),
+			Timestamp: time.Now(),
+		})
 	} else if form.RemoveUser != nil {
-		if err := l.RemoveUserFromGroup(*form.RemoveUser, thinGroup.DN()); err != nil {
+		if err := a.removeUserFromGroup(c.UserContext(), l, *form.RemoveUser, thinGroup.DN()); err != nil {
+			if a.credentialsRevoked(err) {
+				return a.expireSession(c, sess)
+			}
+
 			c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
 			return templates.Group(
 				group, unassignedUsers, templates.Flashes(
-					templates.ErrorFlash("Failed to modify: "+err.Error()),
+					templates.ErrorFlash("Failed to modify: "+explainLDAPError(err)),
 				),
 			).Render(c.UserContext(), c.Response().BodyWriter())
 		}
 
-		a.ldapCache.OnRemoveUserFromGroup(*form.RemoveUser, thinGroup.DN())
+		a.webhook.Notify(webhook.Event{
+			Operation: webhook.OperationRemoveUserFromGroup,
+			Actor:     sess.Get("dn").(string),
+			Target:    *form.RemoveUser,
+			Group:     thinGroup.DN(),
+			Timestamp: time.Now(),
+		})
 	}
 
 	thinGroup, err = a.ldapCache.FindGroupByDN(groupDN)
@@ -167,3 +210,173 @@ func (a *App) findUnassignedUsers(group *ldap_cache.FullLDAPGroup) []ldap.User {
 		return true
 	})
 }
+
+type groupBulkAddForm struct {
+	// Users holds one user DN or sAMAccountName per line.
+	Users string `form:"users"`
+}
+
+func (a *App) groupBulkAddHandler(c *fiber.Ctx) error {
+	sess, err := a.session(c)
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	if sess.Fresh() {
+		return c.Redirect("/login")
+	}
+
+	groupDN, err := url.PathUnescape(c.Params("groupDN"))
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	form := groupBulkAddForm{}
+	if err := c.BodyParser(&form); err != nil {
+		return handle500(c, err)
+	}
+
+	entries := make([]string, 0)
+	for _, line := range strings.Split(form.Users, "\n") {
+		if entry := strings.TrimSpace(line); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	if len(entries) == 0 {
+		return c.Redirect("/groups/" + groupDN)
+	}
+
+	if len(entries) > a.bulkMaxUsers {
+		return a.renderGroup(c, groupDN, templates.Flashes(
+			templates.ErrorFlash(fmt.Sprintf("At most %d users can be added at once, got %d", a.bulkMaxUsers, len(entries))),
+		))
+	}
+
+	l, err := a.sessionToLDAPClient(c.UserContext(), sess)
+	if a.credentialsRevoked(err) {
+		return a.expireSession(c, sess)
+	}
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	thinGroup, err := a.ldapCache.FindGroupByDN(groupDN)
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	// The workers must not touch c, so the context is taken beforehand.
+	ctx := c.UserContext()
+	userDNs := make([]string, len(entries))
+	errs := a.bulk(len(entries), func(i int) error {
+		userDN, err := a.resolveUserDN(entries[i])
+		if err != nil {
+			return err
+		}
+
+		userDNs[i] = userDN
+
+		return a.addUserToGroup(ctx, l, userDN, thinGroup.DN())
+	})
+
+	actor := sess.Get("dn").(string)
+	added := 0
+	revoked := false
+	flashes := templates.Flashes()
+
+	for i, entry := range entries {
+		userDN, err := userDNs[i], errs[i]
+		if err != nil {
+			revoked = revoked || a.credentialsRevoked(err)
+			flashes = append(flashes, templates.ErrorFlash(fmt.Sprintf("Failed to add %s: %s", entry, explainLDAPError(err))))
+
+			continue
+		}
+
+		added++
+		a.webhook.Notify(webhook.Event{
+			Operation: webhook.OperationAddUserToGroup,
+			Actor:     actor,
+			Target:    userDN,
+			Group:     thinGroup.DN(),
+			Timestamp: time.Now(),
+		})
+	}
+
+	// The successful additions are recorded above, so the cache stays correct
+	// even though the user is logged out.
+	if revoked {
+		return a.expireSession(c, sess)
+	}
+
+	if added > 0 {
+		flashes = append(templates.Flashes(templates.SuccessFlash(fmt.Sprintf("Successfully added %d of %d users", added, len(entries)))), flashes...)
+	}
+
+	return a.renderGroup(c, groupDN, flashes)
+}
+
+// bulk runs n modifications with bounded concurrency and returns their errors
+// by index, so the results can be applied in order afterwards.
+func (a *App) bulk(n int, fn func(i int) error) []error {
+	errs := make([]error, n)
+	workers := make(chan struct{}, a.bulkConcurrency)
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		workers <- struct{}{}
+
+		go func(i int) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+
+			errs[i] = fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+
+	return errs
+}
+
+// resolveUserDN accepts either a user DN or a sAMAccountName and returns the DN.
+func (a *App) resolveUserDN(entry string) (string, error) {
+	user, err := a.resolveUser(entry)
+	if err != nil {
+		return "", err
+	}
+
+	return user.DN(), nil
+}
+
+// resolveUser looks up a user by DN or by sAMAccountName.
+func (a *App) resolveUser(entry string) (*ldap.User, error) {
+	if strings.Contains(entry, "=") {
+		return a.ldapCache.FindUserByDN(entry)
+	}
+
+	return a.ldapCache.FindUserBySAMAccountName(entry)
+}
+
+func (a *App) renderGroup(c *fiber.Ctx, groupDN string, flashes []templates.Flash) error {
+	thinGroup, err := a.ldapCache.FindGroupByDN(groupDN)
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	group := a.ldapCache.PopulateUsersForGroup(thinGroup, showDisabledMembers(c))
+	sort.SliceStable(group.Members, func(i, j int) bool {
+		return group.Members[i].CN() < group.Members[j].CN()
+	})
+	unassignedUsers := a.findUnassignedUsers(group)
+	sort.SliceStable(unassignedUsers, func(i, j int) bool {
+		return unassignedUsers[i].CN() < unassignedUsers[j].CN()
+	})
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return templates.Group(group, unassignedUsers, flashes).Render(c.UserContext(), c.Response().BodyWriter())
+}
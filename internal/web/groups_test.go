@@ -0,0 +1,170 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/netresearch/ldap-manager/internal/ldaptest"
+	"github.com/netresearch/ldap-manager/internal/options"
+)
+
+func TestConcurrentGroupModifications(t *testing.T) {
+	a, server := newTestApp(t, nil)
+	server.Intercept(func(req ldaptest.Request) *ldaptest.Result {
+		if req.Operation == "modify" {
+			time.Sleep(time.Millisecond)
+		}
+
+		return nil
+	})
+
+	// Two admins edit the same group at the same time.
+	admins := []string{login(t, a, "jdoe", "jdoe"), login(t, a, "jroe", "jroe")}
+	changes := []url.Values{
+		{"adduser": {testOtherDN}},
+		{"adduser": {testReaderDN}},
+		{"removeuser": {testUserDN}},
+		{"removeuser": {testOtherDN}},
+		{"adduser": {testUserDN}},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		for j, change := range changes {
+			wg.Add(1)
+			go func(cookie string, change url.Values) {
+				defer wg.Done()
+
+				testRequest(t, a, http.MethodPost, "/groups/"+url.PathEscape(testGroupDN), cookie, change)
+			}(admins[(i+j)%2], change)
+		}
+	}
+	wg.Wait()
+
+	if got := server.Count("modify"); got != 4*len(changes) {
+		t.Fatalf("sent %d modifications, want %d", got, 4*len(changes))
+	}
+
+	members := server.Values(testGroupDN, "member")
+	slices.Sort(members)
+
+	group, err := a.ldapCache.FindGroupByDN(testGroupDN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached := slices.Clone(group.Members)
+	slices.Sort(cached)
+
+	if !slices.Equal(cached, members) {
+		t.Errorf("cached members = %v, directory members = %v", cached, members)
+	}
+
+	for _, dn := range []string{testReaderDN, testUserDN, testOtherDN} {
+		user, err := a.ldapCache.FindUserByDN(dn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := slices.Contains(user.Groups, testGroupDN), slices.Contains(members, dn); got != want {
+			t.Errorf("cached groups of %s contain the group: %v, want %v", dn, got, want)
+		}
+	}
+}
+
+func TestBulkBoundsConcurrency(t *testing.T) {
+	a := &App{bulkConcurrency: 3}
+
+	var running, peak atomic.Int32
+	processed := make([]bool, 20)
+	errs := a.bulk(len(processed), func(i int) error {
+		current := running.Add(1)
+		defer running.Add(-1)
+
+		for {
+			max := peak.Load()
+			if current <= max || peak.CompareAndSwap(max, current) {
+				break
+			}
+		}
+
+		time.Sleep(time.Millisecond)
+		processed[i] = true
+
+		if i%2 == 1 {
+			return fmt.Errorf("item %d failed", i)
+		}
+
+		return nil
+	})
+
+	if got := peak.Load(); got > 3 {
+		t.Errorf("ran %d items at once, want at most 3", got)
+	}
+
+	for i, err := range errs {
+		if !processed[i] {
+			t.Errorf("item %d was not processed", i)
+		}
+
+		if wantErr := i%2 == 1; (err != nil) != wantErr {
+			t.Errorf("item %d returned %v, want error %v", i, err, wantErr)
+		}
+	}
+}
+
+func TestGroupBulkAdd(t *testing.T) {
+	a, server := newTestApp(t, func(opts *options.Opts) {
+		opts.BulkConcurrency = 2
+	})
+	cookie := login(t, a, "jdoe", "jdoe")
+
+	form := url.Values{"users": {"jdoe\n" + testOtherDN + "\n\nnobody\n"}}
+	res, body := testRequest(t, a, http.MethodPost, "/groups/"+url.PathEscape(testEmptyDN)+"/members/bulk", cookie, form)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("bulk add answered with %d", res.StatusCode)
+	}
+
+	for _, want := range []string{"Successfully added 2 of 3 users", "Failed to add nobody"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+
+	members := server.Values(testEmptyDN, "member")
+	slices.Sort(members)
+	if want := []string{testOtherDN, testUserDN}; !slices.Equal(members, want) {
+		t.Errorf("directory members = %v, want %v", members, want)
+	}
+
+	group, err := a.ldapCache.FindGroupByDN(testEmptyDN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(group.Members) != 2 {
+		t.Errorf("cached members = %v, want 2", group.Members)
+	}
+}
+
+func TestGroupShowsUnresolvedMembers(t *testing.T) {
+	a, server := newTestApp(t, nil)
+	cookie := login(t, a, "jdoe", "jdoe")
+
+	// Members outside of the base DN are never cached.
+	const groupDN = "cn=partners,ou=groups,dc=example,dc=com"
+	server.AddGroup(groupDN, testUserDN, "cn=guest,dc=partner,dc=org", "cn=vendor,dc=partner,dc=org")
+	if err := a.ldapCache.RefreshGroups(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, body := testRequest(t, a, http.MethodGet, "/groups/"+url.PathEscape(groupDN), cookie, nil)
+	if want := "2 members could not be resolved."; !strings.Contains(body, want) {
+		t.Errorf("group page does not contain %q", want)
+	}
+}
@@ -0,0 +1,115 @@
+package web
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-manager/internal"
+)
+
+type healthResponse struct {
+	Status    string `json:"status"`
+	Users     int    `json:"users"`
+	Groups    int    `json:"groups"`
+	Computers int    `json:"computers"`
+	// Ready is set once the cache has been refreshed successfully.
+	Ready bool `json:"ready"`
+	// CacheAgeSeconds is -1 until the cache has been refreshed successfully once.
+	CacheAgeSeconds int64 `json:"cache_age_seconds"`
+	BindsInFlight   int64 `json:"binds_in_flight"`
+	// DroppedCacheEvents counts cache events not delivered to a lagging subscriber.
+	DroppedCacheEvents uint64 `json:"dropped_cache_events"`
+	// DroppedWebhookEvents counts change notifications which were not sent.
+	DroppedWebhookEvents uint64 `json:"dropped_webhook_events"`
+	// SkippedRefreshes counts the refreshes held back since startup because
+	// they returned far fewer entries than before.
+	SkippedRefreshes uint64 `json:"skipped_refreshes"`
+	// ServingStale is set while refreshes fail and the data of the last
+	// successful refresh is served.
+	ServingStale bool `json:"serving_stale"`
+	// Warnings lists data quality issues found in the directory.
+	Warnings []string `json:"warnings"`
+	// SelfTest is only reported if the periodic self-test is enabled.
+	SelfTest *selfTestResult `json:"self_test,omitempty"`
+}
+
+func (a *App) healthHandler(c *fiber.Ctx) error {
+	return c.JSON(a.health())
+}
+
+func (a *App) health() healthResponse {
+	res := healthResponse{
+		Status:               "ok",
+		Users:                a.ldapCache.Users.Count(),
+		Groups:               a.ldapCache.Groups.Count(),
+		Computers:            a.ldapCache.Computers.Count(),
+		CacheAgeSeconds:      -1,
+		BindsInFlight:        a.binds.InFlight(),
+		DroppedWebhookEvents: a.webhook.Dropped(),
+		Warnings:             make([]string, 0),
+	}
+
+	if a.selfTest != nil {
+		res.SelfTest = a.selfTest.Last()
+		if res.SelfTest != nil && !res.SelfTest.OK {
+			res.Status = "degraded"
+		}
+	}
+
+	for _, name := range a.ldapCache.DuplicateSAMAccountNames() {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("sAMAccountName %q is used by multiple users", name))
+	}
+
+	if a.monitorOnly {
+		return res
+	}
+
+	res.DroppedCacheEvents = a.ldapCache.DroppedEvents()
+	res.SkippedRefreshes = a.ldapCache.SkippedRefreshes()
+	res.ServingStale = a.ldapCache.IsStale()
+	if a.tooStale() {
+		res.Status = "degraded"
+	}
+	for _, kind := range a.ldapCache.PendingDrops() {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("the last refresh returned far fewer %ss than before, the previous ones are kept until the next refresh", kind))
+	}
+
+	if lastRefresh := a.ldapCache.LastRefresh(); !lastRefresh.IsZero() {
+		res.Ready = true
+		res.CacheAgeSeconds = int64(time.Since(lastRefresh).Seconds())
+	} else {
+		res.Status = "starting"
+	}
+
+	return res
+}
+
+// startupHandler is meant for startup probes: it only reports whether the
+// initial cache warmup has finished, regardless of the health of later refreshes.
+// In monitor mode there is no cache, so it waits for the first self-test instead.
+func (a *App) startupHandler(c *fiber.Ctx) error {
+	if a.monitorOnly {
+		if a.selfTest != nil && a.selfTest.Last() == nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"status": "starting"})
+		}
+
+		return c.JSON(fiber.Map{"status": "ok"})
+	}
+
+	if !a.ldapCache.IsWarmedUp() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"status": "starting"})
+	}
+
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// versionHandler reports the build, so the running version can be checked
+// without logging in. It is served in monitor mode as well.
+func (a *App) versionHandler(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"version":         internal.Version,
+		"commit":          internal.CommitHash,
+		"build_timestamp": internal.BuildTimestamp,
+	})
+}
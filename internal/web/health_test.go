@@ -0,0 +1,104 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/netresearch/ldap-manager/internal/ldaptest"
+	"github.com/netresearch/ldap-manager/internal/options"
+)
+
+func TestHealthReportsCache(t *testing.T) {
+	a, _ := newTestApp(t, nil)
+
+	res, body := testRequest(t, a, http.MethodGet, "/health", "", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("health answered with %d", res.StatusCode)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(body), &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	// Computers are users as well in ActiveDirectory, so the reader, the two
+	// users and the computer are counted as users.
+	for name, want := range map[string]float64{"users": 4, "groups": 2, "computers": 1} {
+		if got, ok := fields[name].(float64); !ok || got != want {
+			t.Errorf("%s = %v, want %v", name, fields[name], want)
+		}
+	}
+
+	age, ok := fields["cache_age_seconds"].(float64)
+	if !ok || age < 0 || age > 5 {
+		t.Errorf("cache_age_seconds = %v, want the seconds since the refresh", fields["cache_age_seconds"])
+	}
+	if fields["ready"] != true || fields["status"] != "ok" {
+		t.Errorf("status = %v, ready = %v, want ok and ready", fields["status"], fields["ready"])
+	}
+}
+
+func TestStartupProbe(t *testing.T) {
+	a, server := newColdTestApp(t, nil)
+
+	res, _ := testRequest(t, a, http.MethodGet, "/health/startup", "", nil)
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("startup probe answered with %d before the warmup, want %d", res.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	if err := a.ldapCache.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	res, _ = testRequest(t, a, http.MethodGet, "/health/startup", "", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("startup probe answered with %d after the warmup, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	// Failing refreshes do not concern the startup probe.
+	server.Intercept(func(req ldaptest.Request) *ldaptest.Result {
+		if req.Operation == "search" {
+			return &ldaptest.Result{Code: goldap.LDAPResultUnavailable}
+		}
+
+		return nil
+	})
+	if err := a.ldapCache.Refresh(); err == nil {
+		t.Fatal("refresh succeeded without the server answering searches")
+	}
+
+	res, _ = testRequest(t, a, http.MethodGet, "/health/startup", "", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("startup probe answered with %d after a failed refresh, want %d", res.StatusCode, http.StatusOK)
+	}
+}
+
+func TestMonitorMode(t *testing.T) {
+	a, server := newTestApp(t, func(opts *options.Opts) {
+		opts.Mode = options.ModeMonitor
+	})
+
+	for _, path := range []string{"/health", "/health/startup", "/version"} {
+		res, body := testRequest(t, a, http.MethodGet, path, "", nil)
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("%s answered with %d, want %d", path, res.StatusCode, http.StatusOK)
+		}
+		if !json.Valid([]byte(body)) {
+			t.Errorf("%s answered with %q, want JSON", path, body)
+		}
+	}
+
+	for _, path := range []string{"/", "/login", "/users", "/api/v1/whoami", "/dashboard"} {
+		res, _ := testRequest(t, a, http.MethodGet, path, "", nil)
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("%s answered with %d, want %d", path, res.StatusCode, http.StatusNotFound)
+		}
+	}
+
+	// Without a cache, nothing is searched.
+	if got := server.Count("search"); got != 0 {
+		t.Errorf("sent %d searches in monitor mode", got)
+	}
+}
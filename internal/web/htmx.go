@@ -0,0 +1,20 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-manager/internal/web/templates"
+)
+
+// htmxPartials renders pages as fragments without the layout for HTMX
+// requests. Boosted requests replace the whole body, so they still get the
+// full page.
+func htmxPartials(c *fiber.Ctx) error {
+	// Caches must not serve a fragment for direct navigation or vice versa.
+	c.Vary("HX-Request")
+
+	if c.Get("HX-Request") == "true" && c.Get("HX-Boosted") != "true" {
+		c.SetUserContext(templates.WithPartial(c.UserContext()))
+	}
+
+	return c.Next()
+}
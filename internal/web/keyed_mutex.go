@@ -0,0 +1,73 @@
+package web
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+const keyedMutexStripes = 64
+
+// keyedMutex serializes work per key without serializing unrelated keys. Keys
+// are hashed onto a fixed set of mutexes, so memory does not grow with the
+// amount of keys, at the cost of unrelated keys occasionally sharing one.
+// A caller must not hold more than one key at a time.
+type keyedMutex struct {
+	stripes [keyedMutexStripes]sync.Mutex
+}
+
+func (k *keyedMutex) stripe(key string) *sync.Mutex {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+
+	return &k.stripes[h.Sum32()%keyedMutexStripes]
+}
+
+func (k *keyedMutex) Lock(key string) {
+	k.stripe(key).Lock()
+}
+
+func (k *keyedMutex) Unlock(key string) {
+	k.stripe(key).Unlock()
+}
+
+// addUserToGroup writes a membership and records it in the cache. The write
+// and the cache update hold the lock of the membership, so two writers of the
+// same membership can not apply their cache updates in another order than
+// their writes. Writes to different memberships of a group still run in
+// parallel, as the server applies them as independent value changes.
+func (a *App) addUserToGroup(ctx context.Context, l *ldap.LDAP, userDN, groupDN string) error {
+	key := userDN + "\x00" + groupDN
+	a.memberships.Lock(key)
+	defer a.memberships.Unlock(key)
+
+	err := a.ldapOperation(ctx, "add user to group", groupDN, func() error {
+		return l.AddUserToGroup(userDN, groupDN)
+	})
+	if err != nil {
+		return err
+	}
+
+	a.ldapCache.OnAddUserToGroup(userDN, groupDN)
+
+	return nil
+}
+
+func (a *App) removeUserFromGroup(ctx context.Context, l *ldap.LDAP, userDN, groupDN string) error {
+	key := userDN + "\x00" + groupDN
+	a.memberships.Lock(key)
+	defer a.memberships.Unlock(key)
+
+	err := a.ldapOperation(ctx, "remove user from group", groupDN, func() error {
+		return l.RemoveUserFromGroup(userDN, groupDN)
+	})
+	if err != nil {
+		return err
+	}
+
+	a.ldapCache.OnRemoveUserFromGroup(userDN, groupDN)
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package web
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/netresearch/ldap-manager/internal/ldaptest"
+)
+
+func TestConcurrentMembershipWrites(t *testing.T) {
+	a, server := newTestApp(t, nil)
+
+	// Delayed writes make the writers overlap.
+	server.Intercept(func(req ldaptest.Request) *ldaptest.Result {
+		if req.Operation == "modify" {
+			time.Sleep(time.Millisecond)
+		}
+
+		return nil
+	})
+
+	l, err := a.ldapClient.WithCredentials(testUserDN, "jdoe")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(add bool) {
+			defer wg.Done()
+
+			// Writes are rejected if the membership is already in the
+			// requested state, which leaves the cache as it is.
+			if add {
+				_ = a.addUserToGroup(context.Background(), l, testOtherDN, testGroupDN)
+			} else {
+				_ = a.removeUserFromGroup(context.Background(), l, testOtherDN, testGroupDN)
+			}
+		}(i%2 == 0)
+	}
+	wg.Wait()
+
+	// The directory holds the result of the last write.
+	member := slices.Contains(server.Values(testGroupDN, "member"), testOtherDN)
+
+	group, err := a.ldapCache.FindGroupByDN(testGroupDN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := slices.Contains(group.Members, testOtherDN); got != member {
+		t.Errorf("cached group has the member: %v, directory: %v", got, member)
+	}
+
+	user, err := a.ldapCache.FindUserByDN(testOtherDN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := slices.Contains(user.Groups, testGroupDN); got != member {
+		t.Errorf("cached user is in the group: %v, directory: %v", got, member)
+	}
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	goldap "github.com/go-ldap/ldap/v3"
+	ldap "github.com/netresearch/simple-ldap-go"
+	"github.com/rs/zerolog/log"
+)
+
+// isAccountLocked reports whether a bind failed because ActiveDirectory locked
+// the account. AD reports this as invalid credentials, with the sub-code 775
+// in the diagnostic message.
+func isAccountLocked(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "data 775")
+}
+
+// isInvalidCredentials reports whether the directory rejected the credentials
+// used for an operation.
+func isInvalidCredentials(err error) bool {
+	return goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials)
+}
+
+// isWrongPassword reports whether a credential check failed because the
+// username or the password is wrong. Only these failures are counted by the
+// login limiter: outages, timeouts, exhausted bind slots and locked accounts
+// say nothing about the password and would block legitimate users.
+func isWrongPassword(err error) bool {
+	return errors.Is(err, ldap.ErrUserNotFound) || (isInvalidCredentials(err) && !isAccountLocked(err))
+}
+
+// ldapResultExplanations maps LDAP result codes which commonly occur when
+// modifying memberships to an explanation a help-desk user can act upon.
+var ldapResultExplanations = map[uint16]string{
+	goldap.LDAPResultTimeLimitExceeded:           "The directory server took too long to answer, please try again later.",
+	goldap.LDAPResultNoSuchAttribute:             "The user is not a member of this group (anymore).",
+	goldap.LDAPResultConstraintViolation:         "The directory server rejected the change because it violates a constraint, e.g. the group is a primary group of the user.",
+	goldap.LDAPResultAttributeOrValueExists:      "The user is already a member of this group.",
+	goldap.LDAPResultNoSuchObject:                "The user or group does not exist (anymore), it might have been moved or deleted.",
+	goldap.LDAPResultInvalidCredentials:          "Your credentials are no longer valid, please log in again.",
+	goldap.LDAPResultInsufficientAccessRights:    "You are not allowed to modify this group.",
+	goldap.LDAPResultBusy:                        "The directory server is busy, please try again later.",
+	goldap.LDAPResultUnavailable:                 "The directory server is unavailable, please try again later.",
+	goldap.LDAPResultUnwillingToPerform:          "The directory server refused the change, e.g. because the group is protected or managed by another system.",
+	goldap.LDAPResultEntryAlreadyExists:          "The entry already exists.",
+	goldap.LDAPResultObjectClassViolation:        "The change is not allowed for this kind of object.",
+	goldap.LDAPResultNotAllowedOnNonLeaf:         "The change is not allowed on an entry which has children.",
+	goldap.LDAPResultAdminLimitExceeded:          "The change exceeds a limit set by the directory administrator.",
+	goldap.LDAPResultInappropriateAuthentication: "The directory server does not accept this kind of login for modifications.",
+}
+
+// explainLDAPError returns a human-readable message for err, keeping the raw
+// result code for reference. The raw error is logged, so admins still see
+// the diagnostic message sent by the directory server.
+func explainLDAPError(err error) string {
+	log.Warn().Err(err).Msg("ldap operation failed")
+
+	var ldapErr *goldap.Error
+	if !errors.As(err, &ldapErr) {
+		return err.Error()
+	}
+
+	explanation, ok := ldapResultExplanations[ldapErr.ResultCode]
+	if !ok {
+		return err.Error()
+	}
+
+	return fmt.Sprintf("%s (LDAP result code %d)", explanation, ldapErr.ResultCode)
+}
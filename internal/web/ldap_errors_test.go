@@ -0,0 +1,73 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+func TestExplainLDAPError(t *testing.T) {
+	ldapError := func(code uint16) error {
+		return goldap.NewError(code, errors.New("diagnostic message"))
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			"not a member",
+			ldapError(goldap.LDAPResultNoSuchAttribute),
+			"The user is not a member of this group (anymore). (LDAP result code 16)",
+		},
+		{
+			"already a member",
+			ldapError(goldap.LDAPResultAttributeOrValueExists),
+			"The user is already a member of this group. (LDAP result code 20)",
+		},
+		{
+			"insufficient access rights",
+			ldapError(goldap.LDAPResultInsufficientAccessRights),
+			"You are not allowed to modify this group. (LDAP result code 50)",
+		},
+		{
+			"busy",
+			ldapError(goldap.LDAPResultBusy),
+			"The directory server is busy, please try again later. (LDAP result code 51)",
+		},
+		{
+			"wrapped",
+			fmt.Errorf("could not add user: %w", ldapError(goldap.LDAPResultNoSuchObject)),
+			"The user or group does not exist (anymore), it might have been moved or deleted. (LDAP result code 32)",
+		},
+		{
+			"unknown result code",
+			ldapError(goldap.LDAPResultOther),
+			ldapError(goldap.LDAPResultOther).Error(),
+		},
+		{
+			"no LDAP error",
+			errors.New("connection refused"),
+			"connection refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := explainLDAPError(tt.err); got != tt.want {
+				t.Errorf("explainLDAPError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Every explained result code keeps the code for reference.
+	for code, explanation := range ldapResultExplanations {
+		want := fmt.Sprintf("%s (LDAP result code %d)", explanation, code)
+		if got := explainLDAPError(ldapError(code)); got != want {
+			t.Errorf("explainLDAPError() for result code %d = %q, want %q", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package web
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// busyRetrySeconds is how long clients are asked to wait before retrying a
+// request rejected by concurrencyLimit.
+const busyRetrySeconds = "1"
+
+// concurrencyLimit answers requests with 503 while max requests are already
+// being handled, instead of letting them pile up. Health checks are always
+// answered, so a busy instance is not restarted by its orchestrator.
+func concurrencyLimit(max int) fiber.Handler {
+	slots := make(chan struct{}, max)
+
+	return func(c *fiber.Ctx) error {
+		if strings.HasPrefix(c.Path(), "/health") {
+			return c.Next()
+		}
+
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+
+			return c.Next()
+		default:
+			c.Set(fiber.HeaderRetryAfter, busyRetrySeconds)
+
+			return c.Status(fiber.StatusServiceUnavailable).SendString("The server is busy, please try again later.")
+		}
+	}
+}
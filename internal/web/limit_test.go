@@ -0,0 +1,70 @@
+package web
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+
+	f := fiber.New()
+	f.Use(concurrencyLimit(1))
+	f.Get("/health", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	f.Get("/slow", func(c *fiber.Ctx) error {
+		entered <- struct{}{}
+		<-release
+
+		return c.SendString("done")
+	})
+
+	done := make(chan int)
+	go func() {
+		res, err := f.Test(httptest.NewRequest(fiber.MethodGet, "/slow", nil), -1)
+		if err != nil {
+			t.Error(err)
+			done <- 0
+
+			return
+		}
+
+		done <- res.StatusCode
+	}()
+	<-entered
+
+	res, err := f.Test(httptest.NewRequest(fiber.MethodGet, "/slow", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != fiber.StatusServiceUnavailable || res.Header.Get(fiber.HeaderRetryAfter) != busyRetrySeconds {
+		t.Errorf("saturated request answered with %d and Retry-After %q", res.StatusCode, res.Header.Get(fiber.HeaderRetryAfter))
+	}
+
+	res, err = f.Test(httptest.NewRequest(fiber.MethodGet, "/health", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != fiber.StatusOK {
+		t.Errorf("health check answered with %d while saturated", res.StatusCode)
+	}
+
+	close(release)
+	if status := <-done; status != fiber.StatusOK {
+		t.Errorf("slow request answered with %d", status)
+	}
+
+	// The slot is free again.
+	go func() { <-entered }()
+	res, err = f.Test(httptest.NewRequest(fiber.MethodGet, "/slow", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != fiber.StatusOK {
+		t.Errorf("request after the slot was freed answered with %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,129 @@
+package web
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// loginLimiterPruneSize is the amount of tracked keys above which expired
+// ones are removed, so a scan of many addresses does not grow the map forever.
+const loginLimiterPruneSize = 4096
+
+type failures struct {
+	since time.Time
+	count int
+}
+
+// loginLimiter blocks credential checks for a client address or a username
+// once it failed max times within window, so passwords can not be guessed
+// through the login form or the verification API. A nil limiter allows all
+// attempts.
+type loginLimiter struct {
+	max    int
+	window time.Duration
+	now    func() time.Time
+
+	m        sync.Mutex
+	failures map[string]*failures
+}
+
+// newLoginLimiter returns a limiter allowing max failed attempts per window,
+// or nil if max is zero.
+func newLoginLimiter(max int, window time.Duration) *loginLimiter {
+	if max <= 0 {
+		return nil
+	}
+
+	return &loginLimiter{
+		max:      max,
+		window:   window,
+		now:      time.Now,
+		failures: make(map[string]*failures),
+	}
+}
+
+// loginKeys returns the keys an attempt is counted for: the client address
+// and, if given, the username.
+func loginKeys(ip, username string) []string {
+	keys := []string{"ip:" + ip}
+	if username != "" {
+		keys = append(keys, userKey(username))
+	}
+
+	return keys
+}
+
+// userKey compares usernames case insensitively, like sAMAccountNames.
+func userKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+// Allowed reports whether none of the keys exceeded the allowed failures.
+func (l *loginLimiter) Allowed(keys ...string) bool {
+	if l == nil {
+		return true
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	now := l.now()
+	for _, key := range keys {
+		f, exists := l.failures[key]
+		if exists && now.Sub(f.since) < l.window && f.count >= l.max {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Fail counts a failed attempt for all keys.
+func (l *loginLimiter) Fail(keys ...string) {
+	if l == nil {
+		return
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	now := l.now()
+	if len(l.failures) >= loginLimiterPruneSize {
+		l.prune(now)
+	}
+
+	for _, key := range keys {
+		f, exists := l.failures[key]
+		if !exists || now.Sub(f.since) >= l.window {
+			f = &failures{since: now}
+			l.failures[key] = f
+		}
+
+		f.count++
+	}
+}
+
+// Succeed forgets the failures of the keys after a successful attempt. Only the
+// username should be passed, a client address stays limited even if some of
+// its attempts succeed.
+func (l *loginLimiter) Succeed(keys ...string) {
+	if l == nil {
+		return
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	for _, key := range keys {
+		delete(l.failures, key)
+	}
+}
+
+func (l *loginLimiter) prune(now time.Time) {
+	for key, f := range l.failures {
+		if now.Sub(f.since) >= l.window {
+			delete(l.failures, key)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package web
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestLoginLimiter(max int, window time.Duration) (*loginLimiter, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newLoginLimiter(max, window)
+	l.now = func() time.Time { return now }
+
+	return l, &now
+}
+
+func TestLoginLimiterBlocksAfterMaxFailures(t *testing.T) {
+	l, _ := newTestLoginLimiter(3, time.Minute)
+	keys := loginKeys("192.0.2.1", "jdoe")
+
+	for i := 0; i < 3; i++ {
+		if !l.Allowed(keys...) {
+			t.Fatalf("attempt %d was blocked", i+1)
+		}
+		l.Fail(keys...)
+	}
+
+	if l.Allowed(keys...) {
+		t.Error("attempt after 3 failures was allowed")
+	}
+}
+
+func TestLoginLimiterKeys(t *testing.T) {
+	l, _ := newTestLoginLimiter(1, time.Minute)
+	l.Fail(loginKeys("192.0.2.1", "jdoe")...)
+
+	tests := []struct {
+		name     string
+		ip, user string
+		allowed  bool
+	}{
+		{"same address and user", "192.0.2.1", "jdoe", false},
+		{"same address, other user", "192.0.2.1", "other", false},
+		{"other address, same user", "192.0.2.2", "jdoe", false},
+		{"other address, same user in other case", "192.0.2.2", "JDoe", false},
+		{"other address and user", "192.0.2.2", "other", true},
+		{"other address without user", "192.0.2.2", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := l.Allowed(loginKeys(tt.ip, tt.user)...); got != tt.allowed {
+				t.Errorf("Allowed() = %v, want %v", got, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestLoginLimiterWindowExpires(t *testing.T) {
+	l, now := newTestLoginLimiter(1, time.Minute)
+	keys := loginKeys("192.0.2.1", "jdoe")
+
+	l.Fail(keys...)
+	if l.Allowed(keys...) {
+		t.Fatal("attempt within the window was allowed")
+	}
+
+	*now = now.Add(time.Minute)
+	if !l.Allowed(keys...) {
+		t.Error("attempt after the window was blocked")
+	}
+}
+
+func TestLoginLimiterSucceedOnlyResetsUser(t *testing.T) {
+	l, _ := newTestLoginLimiter(1, time.Minute)
+
+	l.Fail(loginKeys("192.0.2.1", "jdoe")...)
+	l.Succeed(userKey("jdoe"))
+
+	if !l.Allowed(loginKeys("192.0.2.2", "jdoe")...) {
+		t.Error("user was still blocked after a successful attempt")
+	}
+	if l.Allowed(loginKeys("192.0.2.1", "other")...) {
+		t.Error("address was unblocked by a successful attempt")
+	}
+}
+
+func TestLoginLimiterPrunesExpiredKeys(t *testing.T) {
+	l, now := newTestLoginLimiter(1, time.Minute)
+	for i := 0; i < loginLimiterPruneSize; i++ {
+		l.Fail(loginKeys(time.Duration(i).String(), "")...)
+	}
+
+	*now = now.Add(time.Minute)
+	l.Fail(loginKeys("192.0.2.1", "")...)
+
+	if len(l.failures) != 1 {
+		t.Errorf("%d keys are tracked, want 1", len(l.failures))
+	}
+}
+
+func TestDisabledLoginLimiter(t *testing.T) {
+	l := newLoginLimiter(0, time.Minute)
+	if l != nil {
+		t.Fatal("limiter with max 0 is not nil")
+	}
+
+	keys := loginKeys("192.0.2.1", "jdoe")
+	l.Fail(keys...)
+	l.Succeed(keys...)
+
+	if !l.Allowed(keys...) {
+		t.Error("disabled limiter blocked an attempt")
+	}
+}
+
+// failureCount returns the failures counted for key.
+func failureCount(l *loginLimiter, key string) int {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if f, exists := l.failures[key]; exists {
+		return f.count
+	}
+
+	return 0
+}
@@ -0,0 +1,29 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+const sessionCookieName = "session_id"
+
+// secureSessionCookie marks the session cookie as secure for requests that
+// arrived via HTTPS, which includes HTTPS terminated at a trusted proxy.
+func secureSessionCookie(c *fiber.Ctx) error {
+	err := c.Next()
+
+	if c.Protocol() != "https" {
+		return err
+	}
+
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+
+	cookie.SetKey(sessionCookieName)
+	if c.Response().Header.Cookie(cookie) {
+		cookie.SetSecure(true)
+		c.Response().Header.SetCookie(cookie)
+	}
+
+	return err
+}
@@ -0,0 +1,42 @@
+package web
+
+import (
+	"sync"
+	"time"
+
+	"github.com/netresearch/ldap-manager/internal/ldap_cache"
+	"github.com/netresearch/ldap-manager/internal/web/templates"
+)
+
+// recentChangesSize is the amount of cache events kept for the dashboard.
+const recentChangesSize = 20
+
+// recentChanges keeps the latest changes found by the cache refreshes, so the
+// dashboard can show them without querying the directory.
+type recentChanges struct {
+	m       sync.RWMutex
+	changes []templates.RecentChange
+}
+
+// run records events until the channel is closed by unsubscribing.
+func (r *recentChanges) run(events <-chan ldap_cache.CacheEvent) {
+	for event := range events {
+		r.m.Lock()
+		r.changes = append([]templates.RecentChange{{Time: time.Now(), Event: event}}, r.changes...)
+		if len(r.changes) > recentChangesSize {
+			r.changes = r.changes[:recentChangesSize]
+		}
+		r.m.Unlock()
+	}
+}
+
+// Get returns the recorded changes, newest first.
+func (r *recentChanges) Get() []templates.RecentChange {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	changes := make([]templates.RecentChange, len(r.changes))
+	copy(changes, r.changes)
+
+	return changes
+}
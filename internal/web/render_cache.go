@@ -0,0 +1,49 @@
+package web
+
+import (
+	"sync"
+	"time"
+)
+
+// renderCache keeps rendered pages for a short time, for pages which are the
+// same for every user but expensive to render on every request.
+type renderCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	m       sync.Mutex
+	entries map[string]renderedPage
+}
+
+type renderedPage struct {
+	body       []byte
+	renderedAt time.Time
+}
+
+func newRenderCache(ttl time.Duration) *renderCache {
+	return &renderCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]renderedPage),
+	}
+}
+
+// get returns the page rendered for key, unless it is older than the ttl.
+func (r *renderCache) get(key string) ([]byte, bool) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	page, exists := r.entries[key]
+	if !exists || r.now().Sub(page.renderedAt) >= r.ttl {
+		return nil, false
+	}
+
+	return page.body, true
+}
+
+func (r *renderCache) set(key string, body []byte) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.entries[key] = renderedPage{body: body, renderedAt: r.now()}
+}
@@ -0,0 +1,32 @@
+package web
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := newRenderCache(5 * time.Second)
+	r.now = func() time.Time { return now }
+
+	if _, cached := r.get("page"); cached {
+		t.Fatal("empty cache returned a page")
+	}
+
+	r.set("page", []byte("page"))
+	r.set("partial", []byte("partial"))
+
+	now = now.Add(4 * time.Second)
+	if body, cached := r.get("page"); !cached || string(body) != "page" {
+		t.Errorf("get(page) = %q, %v, want the page", body, cached)
+	}
+	if body, cached := r.get("partial"); !cached || string(body) != "partial" {
+		t.Errorf("get(partial) = %q, %v, want the partial page", body, cached)
+	}
+
+	now = now.Add(time.Second)
+	if _, cached := r.get("page"); cached {
+		t.Error("page was returned after the ttl")
+	}
+}
@@ -0,0 +1,145 @@
+package web
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	requestIDKey = "requestid"
+	// userDNKey holds the DN of the signed in user once a handler loaded the
+	// session, see App.session.
+	userDNKey = "userdn"
+)
+
+// requestLog logs every request as a structured entry, which can be filtered
+// by user or request ID with FilterRequestLog to reproduce what a user did.
+// Only the path is logged: query strings and bodies may contain credentials,
+// as the login form is submitted via GET.
+//
+// The user is only known for requests whose handler loaded the session, so
+// static assets and the health endpoints do not cost a session lookup.
+//
+// With a sampleRate above 1, only every n-th successful request is logged.
+// Requests answered with an error status are always logged.
+func requestLog(sampleRate uint32) fiber.Handler {
+	logger := sampled(log.Logger, sampleRate)
+
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		// Errors are handled here instead of by fiber after all middleware
+		// ran, so the logged status is the one sent to the client.
+		if err := c.Next(); err != nil {
+			if err := c.App().ErrorHandler(c, err); err != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		var event *zerolog.Event
+		if c.Response().StatusCode() >= fiber.StatusBadRequest {
+			event = log.Info()
+		} else {
+			event = logger.Info()
+		}
+
+		user, _ := c.Locals(userDNKey).(string)
+		requestID, _ := c.Locals(requestIDKey).(string)
+
+		event.
+			Str("request_id", requestID).
+			Str("method", c.Method()).
+			Str("path", c.Path()).
+			Int("status", c.Response().StatusCode()).
+			Dur("duration", time.Since(start)).
+			Str("user", user).
+			Msg("request")
+
+		return nil
+	}
+}
+
+// session loads the session of the request and records the signed in user
+// for the request log. Handlers use it instead of the session store.
+func (a *App) session(c *fiber.Ctx) (*session.Session, error) {
+	sess, err := a.sessionStore.Get(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if dn, ok := sess.Get("dn").(string); ok {
+		c.Locals(userDNKey, dn)
+	}
+
+	return sess, nil
+}
+
+// sampled returns a logger only emitting every n-th event, for log sites which
+// are hit on every request.
+func sampled(logger zerolog.Logger, n uint32) zerolog.Logger {
+	if n <= 1 {
+		return logger
+	}
+
+	return logger.Sample(&zerolog.BasicSampler{N: n})
+}
+
+// RequestLogFilter selects request log entries. Empty fields match all entries.
+type RequestLogFilter struct {
+	User      string
+	RequestID string
+	// PathPrefix matches all paths starting with it, e.g. "/users".
+	PathPrefix string
+	// Status matches the exact response status, if not zero.
+	Status int
+}
+
+type requestLogEntry struct {
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+	Path      string `json:"path"`
+	Status    int    `json:"status"`
+	User      string `json:"user"`
+}
+
+func (f RequestLogFilter) matches(e requestLogEntry) bool {
+	return e.Message == "request" &&
+		(f.User == "" || strings.EqualFold(e.User, f.User)) &&
+		(f.RequestID == "" || e.RequestID == f.RequestID) &&
+		strings.HasPrefix(e.Path, f.PathPrefix) &&
+		(f.Status == 0 || e.Status == f.Status)
+}
+
+// FilterRequestLog copies the request log entries matching f from a JSON log
+// (LOG_FORMAT=json) to w, in their original order. Other log lines, and lines
+// which are not JSON, are skipped.
+func FilterRequestLog(r io.Reader, w io.Writer, f RequestLogFilter) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	out := bufio.NewWriter(w)
+	for scanner.Scan() {
+		var e requestLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || !f.matches(e) {
+			continue
+		}
+
+		if _, err := out.Write(append(scanner.Bytes(), '\n')); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return out.Flush()
+}
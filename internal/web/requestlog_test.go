@@ -0,0 +1,187 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/netresearch/ldap-manager/internal/options"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func TestSampled(t *testing.T) {
+	tests := []struct {
+		rate uint32
+		want int
+	}{
+		{0, 100},
+		{1, 100},
+		{10, 10},
+		{100, 1},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		logger := sampled(zerolog.New(&buf), tt.rate)
+
+		for i := 0; i < 100; i++ {
+			logger.Info().Msg("request")
+		}
+
+		if got := strings.Count(buf.String(), "\n"); got != tt.want {
+			t.Errorf("sampled(%d) emitted %d of 100 entries, want %d", tt.rate, got, tt.want)
+		}
+	}
+}
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&buf)
+	t.Cleanup(func() { log.Logger = logger })
+
+	return &buf
+}
+
+func TestRequestLogEntry(t *testing.T) {
+	buf := captureLog(t)
+
+	f := fiber.New()
+	f.Use(requestid.New(requestid.Config{ContextKey: requestIDKey}))
+	f.Use(requestLog(1))
+	f.Get("/login", func(c *fiber.Ctx) error {
+		c.Locals(userDNKey, "cn=jdoe,dc=example,dc=com")
+
+		return c.SendStatus(fiber.StatusFound)
+	})
+
+	req := httptest.NewRequest(fiber.MethodGet, "/login?username=jdoe&password=hunter2", strings.NewReader("password=hunter2"))
+	res, err := f.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Errorf("log contains the password: %s", buf.String())
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log entry is not valid JSON: %v", err)
+	}
+
+	want := map[string]any{
+		"level":      "info",
+		"message":    "request",
+		"request_id": res.Header.Get(fiber.HeaderXRequestID),
+		"method":     "GET",
+		"path":       "/login",
+		"status":     float64(fiber.StatusFound),
+		"user":       "cn=jdoe,dc=example,dc=com",
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("%s = %v, want %v", key, entry[key], value)
+		}
+	}
+
+	if entry["request_id"] == "" {
+		t.Error("request_id is empty")
+	}
+	if _, ok := entry["duration"]; !ok {
+		t.Error("duration is missing")
+	}
+}
+
+func TestRequestLogWithoutSession(t *testing.T) {
+	buf := captureLog(t)
+
+	f := fiber.New()
+	f.Use(requestLog(1))
+
+	if _, err := f.Test(httptest.NewRequest(fiber.MethodGet, "/static/styles.css", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log entry is not valid JSON: %v", err)
+	}
+
+	if entry["user"] != "" || entry["status"] != float64(fiber.StatusNotFound) {
+		t.Errorf("entry = %v, want an anonymous 404", entry)
+	}
+}
+
+func TestFilterRequestLog(t *testing.T) {
+	lines := []string{
+		`{"level":"info","request_id":"a","method":"GET","path":"/users","status":200,"user":"cn=jdoe,dc=example,dc=com","message":"request"}`,
+		`{"level":"info","request_id":"b","method":"POST","path":"/groups/cn=g","status":500,"user":"cn=jdoe,dc=example,dc=com","message":"request"}`,
+		`{"level":"info","request_id":"c","method":"GET","path":"/users/cn=x","status":200,"user":"cn=other,dc=example,dc=com","message":"request"}`,
+		`{"level":"info","user":"cn=jdoe,dc=example,dc=com","message":"refreshed users"}`,
+		`not json`,
+	}
+	input := strings.Join(lines, "\n") + "\n"
+
+	tests := []struct {
+		name   string
+		filter RequestLogFilter
+		want   []string
+	}{
+		{"no filter", RequestLogFilter{}, lines[:3]},
+		{"user", RequestLogFilter{User: "CN=jdoe,dc=example,dc=com"}, lines[:2]},
+		{"request ID", RequestLogFilter{RequestID: "b"}, lines[1:2]},
+		{"path prefix", RequestLogFilter{PathPrefix: "/users"}, []string{lines[0], lines[2]}},
+		{"status", RequestLogFilter{Status: 500}, lines[1:2]},
+		{"user and path", RequestLogFilter{User: "cn=jdoe,dc=example,dc=com", PathPrefix: "/users"}, lines[:1]},
+		{"no match", RequestLogFilter{RequestID: "d"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := FilterRequestLog(strings.NewReader(input), &out, tt.filter); err != nil {
+				t.Fatal(err)
+			}
+
+			want := ""
+			if len(tt.want) > 0 {
+				want = strings.Join(tt.want, "\n") + "\n"
+			}
+			if out.String() != want {
+				t.Errorf("FilterRequestLog() =\n%s\nwant\n%s", out.String(), want)
+			}
+		})
+	}
+}
+
+func TestRequestLogSessionRoute(t *testing.T) {
+	// The request log keeps the logger it was created with.
+	buf := captureLog(t)
+	a, _ := newTestApp(t, func(opts *options.Opts) {
+		opts.RequestLogEnabled = true
+	})
+	cookie := login(t, a, "jdoe", "jdoe")
+
+	buf.Reset()
+	res, _ := testRequest(t, a, fiber.MethodGet, "/users", cookie, nil)
+	if res.StatusCode != fiber.StatusOK {
+		t.Fatalf("GET /users answered with %d", res.StatusCode)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log entry is not valid JSON: %v", err)
+	}
+
+	if entry["user"] != testUserDN || entry["path"] != "/users" || entry["status"] != float64(fiber.StatusOK) {
+		t.Errorf("entry = %v, want the signed in user", entry)
+	}
+}
@@ -0,0 +1,122 @@
+package web
+
+import (
+	"sync"
+	"time"
+
+	goldap "github.com/go-ldap/ldap/v3"
+	ldap "github.com/netresearch/simple-ldap-go"
+	"github.com/rs/zerolog/log"
+)
+
+type selfTestResult struct {
+	Time       time.Time `json:"time"`
+	OK         bool      `json:"ok"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// selfTestQuery is the search run by the self-test. Directories which deny
+// base searches to the readonly user can point it at something else.
+type selfTestQuery struct {
+	BaseDN string
+	Filter string
+	Scope  int
+}
+
+// selfTest periodically connects to the directory with the readonly user and
+// runs a cheap search, to notice broken connectivity or rotated credentials
+// before users do.
+type selfTest struct {
+	client   *ldap.LDAP
+	query    selfTestQuery
+	interval time.Duration
+	stop     chan struct{}
+
+	m    sync.RWMutex
+	last *selfTestResult
+}
+
+func newSelfTest(client *ldap.LDAP, query selfTestQuery, interval time.Duration) *selfTest {
+	return &selfTest{
+		client:   client,
+		query:    query,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (s *selfTest) Run() {
+	t := time.NewTicker(s.interval)
+
+	s.runOnce()
+
+	for {
+		select {
+		case <-s.stop:
+			t.Stop()
+
+			return
+		case <-t.C:
+			s.runOnce()
+		}
+	}
+}
+
+func (s *selfTest) Stop() {
+	close(s.stop)
+}
+
+func (s *selfTest) runOnce() {
+	start := time.Now()
+
+	err := s.search()
+
+	result := &selfTestResult{
+		Time:       start,
+		OK:         err == nil,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+		log.Error().Err(err).Msg("LDAP self-test failed")
+	}
+
+	s.m.Lock()
+	s.last = result
+	s.m.Unlock()
+}
+
+// search binds with the readonly user and runs the configured query. Finding
+// no entries is fine, only errors of the bind or the search itself count.
+func (s *selfTest) search() error {
+	c, err := s.client.GetConnection()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	_, err = c.Search(&goldap.SearchRequest{
+		BaseDN:       s.query.BaseDN,
+		Scope:        s.query.Scope,
+		DerefAliases: goldap.NeverDerefAliases,
+		SizeLimit:    1,
+		Filter:       s.query.Filter,
+		// "1.1" requests no attributes at all.
+		Attributes: []string{"1.1"},
+	})
+	// Hitting the size limit proves that the search works.
+	if goldap.IsErrorWithCode(err, goldap.LDAPResultSizeLimitExceeded) {
+		return nil
+	}
+
+	return err
+}
+
+// Last returns the result of the latest self-test, or nil if none ran yet.
+func (s *selfTest) Last() *selfTestResult {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	return s.last
+}
@@ -1,11 +1,13 @@
 package web
 
 import (
+	"context"
 	"net/http"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/filesystem"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/gofiber/fiber/v2/middleware/session"
 	"github.com/gofiber/storage/bbolt/v2"
 	"github.com/gofiber/storage/memory/v2"
@@ -13,6 +15,7 @@ import (
 	"github.com/netresearch/ldap-manager/internal/options"
 	"github.com/netresearch/ldap-manager/internal/web/static"
 	"github.com/netresearch/ldap-manager/internal/web/templates"
+	"github.com/netresearch/ldap-manager/internal/webhook"
 	ldap "github.com/netresearch/simple-ldap-go"
 	"github.com/rs/zerolog/log"
 )
@@ -20,17 +23,50 @@ import (
 type App struct {
 	ldapClient   *ldap.LDAP
 	ldapCache    *ldap_cache.Manager
+	binds        *bindLimiter
 	sessionStore *session.Store
-	fiber        *fiber.App
+	// sessionStorage backs sessionStore and is closed on shutdown, which
+	// releases the lock on the BBolt database file.
+	sessionStorage fiber.Storage
+	webhook        *webhook.Notifier
+	selfTest       *selfTest
+	fiber          *fiber.App
+	branding       templates.Branding
+	// monitorOnly skips the LDAP cache and serves nothing but the health and
+	// version endpoints.
+	monitorOnly bool
+	started     time.Time
+	// settings is the redacted configuration shown on the about page.
+	settings      []templates.Setting
+	recentChanges recentChanges
+	// dashboardCache holds the rendered dashboard, which is the same for
+	// all users.
+	dashboardCache *renderCache
+	// memberships serializes writes and cache updates per membership.
+	memberships keyedMutex
+	// loginLimiter counts failed logins and credential verifications.
+	loginLimiter *loginLimiter
+	unsubscribe  func()
+
+	showDisabledDefault  bool
+	logoutOnInvalidCreds bool
+	slowQueryThreshold   time.Duration
+	bulkMaxUsers         int
+	bulkConcurrency      int
+	dnValidation         options.DNValidation
+	defaultLanding       options.Landing
+	editableAttributes   []string
+	ldifExportAttributes []string
+	staleServeMaxAge     time.Duration
 }
 
 func getSessionStorage(opts *options.Opts) fiber.Storage {
 	if opts.PersistSessions {
-		return bbolt.New(bbolt.Config{
+		return newExpiringStorage(bbolt.New(bbolt.Config{
 			Database: opts.SessionPath,
-			Bucket:   "sessions",
+			Bucket:   sessionBucket,
 			Reset:    false,
-		})
+		}), opts.SessionCleanupInterval, opts.SessionDuration)
 	}
 
 	return memory.New()
@@ -41,43 +77,171 @@ func NewApp(opts *options.Opts) (*App, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Only the DN is logged, so operators can verify the configured service
+	// account. The password must never end up in the logs.
+	log.Info().Str("server", opts.LDAP.Server).Str("readonly_user", opts.ReadonlyUser).Msg("using LDAP readonly user")
 
+	sessionStorage := getSessionStorage(opts)
 	sessionStore := session.New(session.Config{
-		Storage:        getSessionStorage(opts),
+		Storage:        sessionStorage,
 		Expiration:     opts.SessionDuration,
 		CookieHTTPOnly: true,
 		CookieSameSite: "Strict",
+		CookieSecure:   opts.CookieSecure == options.CookieSecureAlways,
+		KeyLookup:      "cookie:" + sessionCookieName,
 	})
 
 	f := fiber.New(fiber.Config{
 		AppName:      "netresearch/ldap-manager",
 		BodyLimit:    4 * 1024,
 		ErrorHandler: handle500,
+		// X-Forwarded-Proto and X-Forwarded-For are only honored for requests
+		// from the configured proxies. Other requests use the address of the
+		// connection, so a client can not pick the address the login limiter
+		// counts its failures for.
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          opts.TrustedProxies,
+		ProxyHeader:             fiber.HeaderXForwardedFor,
+		EnableIPValidation:      true,
+	})
+	if opts.RequestLogEnabled {
+		f.Use(requestid.New(requestid.Config{
+			ContextKey: requestIDKey,
+		}))
+		f.Use(requestLog(opts.LogSampleRate))
+	}
+	if opts.MaxConcurrentRequests > 0 {
+		f.Use(concurrencyLimit(opts.MaxConcurrentRequests))
+	}
+	if opts.CookieSecure == options.CookieSecureAuto {
+		f.Use(secureSessionCookie)
+	}
+	branding := templates.Branding{
+		Title:   opts.AppTitle,
+		LogoURL: opts.AppLogoURL,
+	}
+	f.Use(withBranding(branding))
+	if opts.HTMXPartials {
+		f.Use(htmxPartials)
+	}
+	if opts.CSPPolicy != "" {
+		f.Use(contentSecurityPolicy(opts.CSPPolicy))
+	}
+	f.Use(compressAbove(opts.CompressionMinSize))
+	// The timeout runs inside the compression, so a replaced response is not
+	// sent with the encoding of the original one.
+	if opts.RequestTimeout > 0 {
+		f.Use(requestTimeout(opts.RequestTimeout))
+	}
+	if opts.Mode != options.ModeMonitor {
+		f.Use("/static", filesystem.New(filesystem.Config{
+			Root:   http.FS(static.Static),
+			MaxAge: 24 * 60 * 60,
+		}))
+	}
+
+	ldapCache := ldap_cache.New(ldapClient, ldap_cache.Config{
+		SlowOperationThreshold: opts.SlowQueryThreshold,
+		Schedule:               opts.CacheRefreshSchedule,
+		Stagger:                opts.CacheRefreshStagger,
+		SortOrder:              opts.CacheSort,
+		MaxDropPercent:         opts.CacheMaxDropPercent,
+		IndexShards:            opts.CacheIndexShards,
+		BaseDN:                 opts.LDAP.BaseDN,
+		UserFilter:             opts.LDAPUserFilter,
+		GroupFilter:            opts.LDAPGroupFilter,
+		ComputerFilter:         opts.LDAPComputerFilter,
+		Controls:               opts.LDAPSearchControls,
 	})
-	f.Use(compress.New(compress.Config{
-		Level: compress.LevelBestSpeed,
-	}))
-	f.Use("/static", filesystem.New(filesystem.Config{
-		Root:   http.FS(static.Static),
-		MaxAge: 24 * 60 * 60,
-	}))
 
 	a := &App{
-		ldapClient:   ldapClient,
-		ldapCache:    ldap_cache.New(ldapClient),
-		sessionStore: sessionStore,
-		fiber:        f,
+		ldapClient:     ldapClient,
+		ldapCache:      ldapCache,
+		binds:          newBindLimiter(opts.LDAPMaxConcurrentBinds, opts.LDAPBindWaitWarning),
+		sessionStore:   sessionStore,
+		sessionStorage: sessionStorage,
+		fiber:          f,
+		branding:       branding,
+		monitorOnly:    opts.Mode == options.ModeMonitor,
+		started:        time.Now(),
+		settings:       aboutSettings(opts),
+		loginLimiter:   newLoginLimiter(opts.LoginMaxFailures, opts.LoginFailureWindow),
+		dashboardCache: newRenderCache(dashboardCacheTTL),
+
+		showDisabledDefault:  opts.ShowDisabledDefault,
+		logoutOnInvalidCreds: opts.LogoutOnInvalidCreds,
+		slowQueryThreshold:   opts.SlowQueryThreshold,
+		bulkMaxUsers:         opts.BulkMaxUsers,
+		bulkConcurrency:      opts.BulkConcurrency,
+		dnValidation:         opts.DNValidation,
+		defaultLanding:       opts.DefaultLanding,
+		editableAttributes:   opts.EditableAttributes,
+		ldifExportAttributes: opts.LDIFExportAttributes,
+		staleServeMaxAge:     opts.StaleServeMaxAge,
 	}
 
+	if opts.WebhookURL != "" {
+		a.webhook = webhook.New(opts.WebhookURL, opts.WebhookSecret)
+	}
+
+	if opts.SelfTestInterval > 0 {
+		a.selfTest = newSelfTest(ldapClient, selfTestQuery{
+			BaseDN: opts.SelfTestBaseDN,
+			Filter: opts.SelfTestFilter,
+			Scope:  opts.SelfTestScope,
+		}, opts.SelfTestInterval)
+	}
+
+	f.Get("/health", a.healthHandler)
+	f.Get("/health/startup", a.startupHandler)
+	f.Get("/version", a.versionHandler)
+
+	if a.monitorOnly {
+		f.Use(func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusNotFound)
+		})
+
+		return a, nil
+	}
+
+	f.Use(a.waitForWarmup)
+	if opts.StaleServeMaxAge > 0 {
+		f.Use(a.rejectStale)
+	}
+	if opts.CacheAgeHeaderHTML {
+		f.Use(a.cacheAge)
+	} else {
+		f.Use("/api", a.cacheAge)
+	}
+
+	dn := dnGuard(opts.MaxDNLength, opts.MaxDNDepth)
+
+	f.Get("/favicon.ico", a.faviconHandler)
+	f.Get("/site.webmanifest", a.manifestHandler)
+	f.Get("/api/v1/whoami", a.whoamiHandler)
+	if opts.AuthVerifyEnabled {
+		f.Post("/api/v1/auth/verify", a.verifyCredentialsHandler)
+	}
+	f.Get("/api/v1/users/compare", a.usersCompareAPIHandler)
 	f.Get("/", a.indexHandler)
 	f.Get("/users", a.usersHandler)
-	f.Get("/users/:userDN", a.userHandler)
-	f.Post("/users/:userDN", a.userModifyHandler)
+	f.Get("/users/compare", a.usersCompareHandler)
+	f.Get("/users/:userDN", dn("userDN"), a.userHandler)
+	f.Post("/users/:userDN", dn("userDN"), a.userModifyHandler)
+	f.Post("/users/:userDN/copy-from", dn("userDN"), a.userCopyFromHandler)
+	if len(opts.EditableAttributes) > 0 {
+		f.Get("/users/:userDN/attributes", dn("userDN"), a.userAttributesHandler)
+		f.Post("/users/:userDN/attributes", dn("userDN"), a.userAttributesModifyHandler)
+	}
 	f.Get("/groups", a.groupsHandler)
-	f.Get("/groups/:groupDN", a.groupHandler)
-	f.Post("/groups/:groupDN", a.groupModifyHandler)
+	f.Get("/groups/:groupDN", dn("groupDN"), a.groupHandler)
+	f.Post("/groups/:groupDN", dn("groupDN"), a.groupModifyHandler)
+	f.Post("/groups/:groupDN/members/bulk", dn("groupDN"), a.groupBulkAddHandler)
 	f.Get("/computers", a.computersHandler)
-	f.Get("/computers/:computerDN", a.computerHandler)
+	f.Get("/computers/:computerDN", dn("computerDN"), a.computerHandler)
+	f.Get("/admin/about", a.aboutHandler)
+	f.Get("/dashboard", a.dashboardHandler)
+	f.Get("/export/ldif", a.exportLDIFHandler)
 	f.Get("/login", a.loginHandler)
 	f.Get("/logout", a.logoutHandler)
 
@@ -87,11 +251,60 @@ func NewApp(opts *options.Opts) (*App, error) {
 }
 
 func (a *App) Listen(addr string) error {
-	go a.ldapCache.Run()
+	if !a.monitorOnly {
+		events, unsubscribe := a.ldapCache.Subscribe()
+		a.unsubscribe = unsubscribe
+		go a.recentChanges.run(events)
+		go a.ldapCache.Run()
+	}
+	go a.webhook.Run()
+	if storage, ok := a.sessionStorage.(*expiringStorage); ok {
+		go storage.Run()
+	}
+	if a.selfTest != nil {
+		go a.selfTest.Run()
+	}
 
 	return a.fiber.Listen(addr)
 }
 
+// Shutdown stops accepting requests and waits for open ones until ctx is done,
+// then stops the background workers.
+func (a *App) Shutdown(ctx context.Context) error {
+	err := a.fiber.ShutdownWithContext(ctx)
+
+	if !a.monitorOnly {
+		a.ldapCache.Stop()
+		a.unsubscribe()
+	}
+	a.webhook.Stop()
+	if a.selfTest != nil {
+		a.selfTest.Stop()
+	}
+
+	if closeErr := a.sessionStorage.Close(); closeErr != nil {
+		log.Error().Err(closeErr).Msg("could not close session storage")
+	}
+
+	return err
+}
+
+func withBranding(branding templates.Branding) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.SetUserContext(templates.WithBranding(c.UserContext(), branding))
+
+		return c.Next()
+	}
+}
+
+func contentSecurityPolicy(policy string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentSecurityPolicy, policy)
+
+		return c.Next()
+	}
+}
+
 func handle500(c *fiber.Ctx, err error) error {
 	log.Error().Err(err).Send()
 
@@ -100,7 +313,7 @@ func handle500(c *fiber.Ctx, err error) error {
 }
 
 func (a *App) indexHandler(c *fiber.Ctx) error {
-	sess, err := a.sessionStore.Get(c)
+	sess, err := a.session(c)
 	if err != nil {
 		return handle500(c, err)
 	}
@@ -110,6 +323,10 @@ func (a *App) indexHandler(c *fiber.Ctx) error {
 		return c.Redirect("/login")
 	}
 
+	if a.defaultLanding != options.LandingProfile {
+		return c.Redirect("/" + string(a.defaultLanding))
+	}
+
 	user, err := a.ldapCache.FindUserByDN(sess.Get("dn").(string))
 	if err != nil {
 		return handle500(c, err)
@@ -124,11 +341,83 @@ func (a *App) fourOhFourHandler(c *fiber.Ctx) error {
 	return templates.FourOhFour(c.Path()).Render(c.UserContext(), c.Response().BodyWriter())
 }
 
-func (a *App) sessionToLDAPClient(sess *session.Session) (*ldap.LDAP, error) {
+func (a *App) sessionToLDAPClient(ctx context.Context, sess *session.Session) (*ldap.LDAP, error) {
 	executor, err := a.ldapCache.FindUserByDN(sess.Get("dn").(string))
 	if err != nil {
 		return nil, err
 	}
 
-	return a.ldapClient.WithCredentials(executor.DN(), sess.Get("password").(string))
+	var l *ldap.LDAP
+	err = a.ldapOperation(ctx, "bind", executor.DN(), func() (err error) {
+		l, err = a.ldapClient.WithCredentials(executor.DN(), sess.Get("password").(string))
+
+		return err
+	})
+
+	return l, err
+}
+
+// ldapOperation runs an operation binding with user credentials, bounded by the
+// bind limit and timed for the slow operation log. It is not started once ctx
+// is done, e.g. because the request timed out while waiting for a bind slot.
+func (a *App) ldapOperation(ctx context.Context, operation, target string, fn func() error) error {
+	return a.binds.do(ctx, func() error {
+		return ldap_cache.TimeOperation(a.slowQueryThreshold, operation, target, fn)
+	})
+}
+
+// showDisabled resolves whether disabled entities should be listed. An explicit
+// "show-disabled" query parameter is remembered in the session, otherwise the
+// remembered preference or the configured default is used.
+// Saving the session releases it, so it is only used by list handlers which do
+// not access sess afterwards.
+func (a *App) showDisabled(c *fiber.Ctx, sess *session.Session) bool {
+	query := c.Query("show-disabled")
+	if query == "" {
+		if v, ok := sess.Get("show_disabled").(bool); ok {
+			return v
+		}
+
+		return a.showDisabledDefault
+	}
+
+	showDisabled := query == "1"
+	sess.Set("show_disabled", showDisabled)
+	if err := sess.Save(); err != nil {
+		log.Warn().Err(err).Msg("could not persist show-disabled preference")
+	}
+
+	return showDisabled
+}
+
+// showDisabledMembers resolves whether disabled members are listed on a group
+// page. A group page shows all members unless "show-disabled=0" is given, the
+// preference of the lists does not apply to it.
+func showDisabledMembers(c *fiber.Ctx) bool {
+	return c.Query("show-disabled", "1") == "1"
+}
+
+// sortOrder returns the order requested with the "sort" query parameter. Lists
+// are kept sorted by the cache, so they only need sorting if it is set.
+func sortOrder(c *fiber.Ctx) (ldap_cache.SortOrder, bool) {
+	order := ldap_cache.SortOrder(c.Query("sort"))
+	switch order {
+	case ldap_cache.SortByCN, ldap_cache.SortByName, ldap_cache.SortByDN:
+		return order, true
+	default:
+		return "", false
+	}
+}
+
+// emptyState distinguishes a list emptied by the current filter from a
+// directory which has no entries of that kind at all.
+func emptyState(shown, total int) templates.EmptyState {
+	switch {
+	case shown > 0:
+		return templates.NotEmpty
+	case total > 0:
+		return templates.EmptyFiltered
+	default:
+		return templates.EmptyDirectory
+	}
 }
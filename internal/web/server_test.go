@@ -0,0 +1,74 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/netresearch/ldap-manager/internal/options"
+)
+
+func TestBranding(t *testing.T) {
+	a, _ := newTestApp(t, func(opts *options.Opts) {
+		opts.AppTitle = "Acme Directory"
+		opts.AppLogoURL = "https://cdn.example.com/acme.svg"
+	})
+
+	_, body := testRequest(t, a, http.MethodGet, "/login", "", nil)
+	for _, want := range []string{
+		"<title>Login - Acme Directory</title>",
+		`<img src="https://cdn.example.com/acme.svg" alt="Acme Directory"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("login page does not contain %s", want)
+		}
+	}
+
+	cookie := login(t, a, "jdoe", "jdoe")
+	_, body = testRequest(t, a, http.MethodGet, "/", cookie, nil)
+	if want := "<title>Home - Acme Directory</title>"; !strings.Contains(body, want) {
+		t.Errorf("home page does not contain %s", want)
+	}
+	if strings.Contains(body, "LDAP Manager") {
+		t.Error("home page still shows the default title")
+	}
+}
+
+func TestDefaultLanding(t *testing.T) {
+	tests := []struct {
+		landing  options.Landing
+		location string
+	}{
+		{options.LandingUsers, "/users"},
+		{options.LandingGroups, "/groups"},
+		{options.LandingComputers, "/computers"},
+		{options.LandingDashboard, "/dashboard"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.landing), func(t *testing.T) {
+			a, _ := newTestApp(t, func(opts *options.Opts) {
+				opts.DefaultLanding = tt.landing
+			})
+			cookie := login(t, a, "jdoe", "jdoe")
+
+			res, _ := testRequest(t, a, http.MethodGet, "/", cookie, nil)
+			if res.StatusCode != http.StatusFound || res.Header.Get("Location") != tt.location {
+				t.Errorf("/ answered with %d to %q, want a redirect to %q", res.StatusCode, res.Header.Get("Location"), tt.location)
+			}
+		})
+	}
+
+	t.Run(string(options.LandingProfile), func(t *testing.T) {
+		a, _ := newTestApp(t, nil)
+		cookie := login(t, a, "jdoe", "jdoe")
+
+		res, body := testRequest(t, a, http.MethodGet, "/", cookie, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("/ answered with %d, want %d", res.StatusCode, http.StatusOK)
+		}
+		if !strings.Contains(body, "John Doe") {
+			t.Error("/ does not show the profile of the user")
+		}
+	})
+}
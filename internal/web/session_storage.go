@@ -0,0 +1,195 @@
+package web
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/gofiber/storage/bbolt/v2"
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	sessionBucket = "sessions"
+
+	// expiringMarker starts every value written by expiringStorage. Sessions
+	// are gob encoded, and gob streams never start with a zero byte.
+	expiringMarker    = 0x00
+	expiringHeaderLen = 1 + 8
+)
+
+// expiringStorage wraps the BBolt storage, which ignores expirations, and
+// stores the expiration time in front of every value. Expired sessions are
+// dropped when they are read and removed periodically by Run, so the
+// database does not grow forever.
+//
+// Sessions written before expirations were stored are still read as they
+// are, so upgrading does not log everybody out. Run migrates them to expire
+// after legacyExpiration, the configured session duration.
+type expiringStorage struct {
+	*bbolt.Storage
+	interval         time.Duration
+	legacyExpiration time.Duration
+	stop             chan struct{}
+}
+
+func newExpiringStorage(storage *bbolt.Storage, interval, legacyExpiration time.Duration) *expiringStorage {
+	return &expiringStorage{
+		Storage:          storage,
+		interval:         interval,
+		legacyExpiration: legacyExpiration,
+		stop:             make(chan struct{}),
+	}
+}
+
+func (s *expiringStorage) Get(key string) ([]byte, error) {
+	value, err := s.Storage.Get(key)
+	if err != nil || value == nil {
+		return value, err
+	}
+
+	data, ok, _ := decodeExpiring(value, time.Now())
+	if !ok {
+		return nil, s.Storage.Delete(key)
+	}
+
+	return data, nil
+}
+
+func (s *expiringStorage) Set(key string, value []byte, exp time.Duration) error {
+	if len(key) == 0 || len(value) == 0 {
+		return nil
+	}
+
+	// 0 means that the value never expires.
+	var expiresAt time.Time
+	if exp > 0 {
+		expiresAt = time.Now().Add(exp)
+	}
+
+	return s.Storage.Set(key, encodeExpiring(value, expiresAt), exp)
+}
+
+// encodeExpiring puts the expiration header in front of value. A zero
+// expiresAt never expires.
+func encodeExpiring(value []byte, expiresAt time.Time) []byte {
+	var unix int64
+	if !expiresAt.IsZero() {
+		unix = expiresAt.Unix()
+	}
+
+	buf := make([]byte, expiringHeaderLen+len(value))
+	buf[0] = expiringMarker
+	binary.BigEndian.PutUint64(buf[1:expiringHeaderLen], uint64(unix))
+	copy(buf[expiringHeaderLen:], value)
+
+	return buf
+}
+
+// decodeExpiring returns the value stored behind the expiration header. It
+// reports false for expired values. Values written before expirations were
+// stored have no header and are returned as they are, reported as legacy.
+func decodeExpiring(value []byte, now time.Time) (data []byte, ok, legacy bool) {
+	if len(value) > 0 && value[0] != expiringMarker {
+		return value, true, true
+	}
+
+	if len(value) < expiringHeaderLen {
+		return nil, false, false
+	}
+
+	expiresAt := int64(binary.BigEndian.Uint64(value[1:expiringHeaderLen]))
+	if expiresAt != 0 && now.Unix() >= expiresAt {
+		return nil, false, false
+	}
+
+	return value[expiringHeaderLen:], true, false
+}
+
+// Run migrates the sessions written before expirations were stored right
+// away and then removes expired sessions every interval.
+func (s *expiringStorage) Run() {
+	s.runCleanup()
+
+	t := time.NewTicker(s.interval)
+
+	for {
+		select {
+		case <-s.stop:
+			t.Stop()
+
+			return
+		case <-t.C:
+			s.runCleanup()
+		}
+	}
+}
+
+func (s *expiringStorage) runCleanup() {
+	removed, migrated, err := s.cleanup(time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("could not clean up expired sessions")
+
+		return
+	}
+
+	if migrated > 0 {
+		log.Info().Msgf("migrated %d sessions to expire after %s", migrated, s.legacyExpiration)
+	}
+	log.Debug().Msgf("removed %d expired sessions", removed)
+}
+
+// Close stops the cleanup and closes the database.
+func (s *expiringStorage) Close() error {
+	close(s.stop)
+
+	return s.Storage.Close()
+}
+
+func (s *expiringStorage) cleanup(now time.Time) (removed, migrated int, err error) {
+
+	err = s.Conn().Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(sessionBucket))
+		if b == nil {
+			return nil
+		}
+
+		// Modifying while iterating with a cursor skips entries, so the
+		// changes are collected first.
+		expired := make([][]byte, 0)
+		legacy := make(map[string][]byte)
+		err := b.ForEach(func(k, v []byte) error {
+			data, ok, isLegacy := decodeExpiring(v, now)
+			switch {
+			case !ok:
+				expired = append(expired, append([]byte(nil), k...))
+			case isLegacy:
+				legacy[string(k)] = encodeExpiring(data, now.Add(s.legacyExpiration))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		for k, v := range legacy {
+			if err := b.Put([]byte(k), v); err != nil {
+				return err
+			}
+		}
+
+		removed = len(expired)
+		migrated = len(legacy)
+
+		return nil
+	})
+
+	return removed, migrated, err
+}
@@ -0,0 +1,195 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/storage/bbolt/v2"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestDecodeExpiring(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	value := []byte("session")
+
+	tests := []struct {
+		name       string
+		stored     []byte
+		ok, legacy bool
+	}{
+		{"not expired", encodeExpiring(value, now.Add(time.Minute)), true, false},
+		{"never expires", encodeExpiring(value, time.Time{}), true, false},
+		{"expired", encodeExpiring(value, now), false, false},
+		{"legacy", value, true, true},
+		{"truncated header", []byte{expiringMarker, 1, 2}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, ok, legacy := decodeExpiring(tt.stored, now)
+			if ok != tt.ok || legacy != tt.legacy {
+				t.Fatalf("decodeExpiring() = ok %v, legacy %v, want ok %v, legacy %v", ok, legacy, tt.ok, tt.legacy)
+			}
+
+			if ok && !bytes.Equal(data, value) {
+				t.Errorf("decodeExpiring() = %q, want %q", data, value)
+			}
+		})
+	}
+}
+
+func newTestExpiringStorage(t *testing.T) *expiringStorage {
+	t.Helper()
+
+	s := newExpiringStorage(bbolt.New(bbolt.Config{
+		Database: filepath.Join(t.TempDir(), "session.bbolt"),
+		Bucket:   sessionBucket,
+	}), time.Hour, 30*time.Minute)
+	t.Cleanup(func() { _ = s.Storage.Close() })
+
+	return s
+}
+
+func TestExpiringStorageReadsLegacySessions(t *testing.T) {
+	s := newTestExpiringStorage(t)
+
+	// Written by a version storing the bare session.
+	if err := s.Storage.Set("legacy", []byte("session"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Get("legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "session" {
+		t.Errorf("Get() = %q, want the legacy session", got)
+	}
+}
+
+func TestExpiringStorageCleanup(t *testing.T) {
+	s := newTestExpiringStorage(t)
+	now := time.Now()
+
+	if err := s.Set("active", []byte("active"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Storage.Set("expired", encodeExpiring([]byte("expired"), now.Add(-time.Minute)), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Storage.Set("legacy", []byte("legacy"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, migrated, err := s.cleanup(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 || migrated != 1 {
+		t.Errorf("cleanup() removed %d and migrated %d, want 1 and 1", removed, migrated)
+	}
+
+	for key, want := range map[string]string{"active": "active", "expired": "", "legacy": "legacy"} {
+		got, err := s.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("Get(%q) = %q, want %q", key, got, want)
+		}
+	}
+
+	// The migrated session expires after the session duration.
+	stored, err := s.Storage.Get("legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, legacy := decodeExpiring(stored, now.Add(30*time.Minute)); ok || legacy {
+		t.Errorf("migrated session is still valid after the session duration")
+	}
+}
+
+func TestExpiringStorageRemovesLegacySessionsAfterMigration(t *testing.T) {
+	s := newTestExpiringStorage(t)
+	now := time.Now()
+
+	if err := s.Storage.Set("legacy", []byte("legacy"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, migrated, err := s.cleanup(now); err != nil || migrated != 1 {
+		t.Fatalf("cleanup() migrated %d sessions, %v, want 1", migrated, err)
+	}
+
+	// A later cleanup removes the session once the session duration passed.
+	removed, migrated, err := s.cleanup(now.Add(30 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 || migrated != 0 {
+		t.Errorf("cleanup() removed %d and migrated %d, want 1 and 0", removed, migrated)
+	}
+
+	stored, err := s.Storage.Get("legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != nil {
+		t.Errorf("legacy session is still stored: %q", stored)
+	}
+}
+
+func TestShutdownClosesSessionDatabase(t *testing.T) {
+	server := newTestServer(t)
+	opts := testOpts(server)
+	opts.PersistSessions = true
+	opts.SessionPath = filepath.Join(t.TempDir(), "session.bbolt")
+
+	a, err := NewApp(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listening := make(chan struct{})
+	a.fiber.Hooks().OnListen(func(fiber.ListenData) error {
+		close(listening)
+
+		return nil
+	})
+	go func() { _ = a.Listen("127.0.0.1:0") }()
+	<-listening
+
+	if err := a.ldapCache.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	cookie := login(t, a, "jdoe", "jdoe")
+
+	if err := a.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// BBolt locks the file while it is open, so the timeout only passes if
+	// the database was not closed.
+	db, err := bolt.Open(opts.SessionPath, 0o600, &bolt.Options{Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("could not reopen the session database: %v", err)
+	}
+	defer db.Close()
+
+	// The session survives the restart.
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(sessionBucket))
+		if b == nil || b.Get([]byte(cookie)) == nil {
+			t.Error("session was not persisted")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
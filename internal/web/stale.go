@@ -0,0 +1,49 @@
+package web
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-manager/internal/web/templates"
+)
+
+// staleRetrySeconds is how long clients are asked to wait before retrying a
+// request rejected because the cache is too old.
+const staleRetrySeconds = "30"
+
+// tooStale reports whether refreshes are failing and the cache is older than
+// the configured maximum age.
+func (a *App) tooStale() bool {
+	if a.staleServeMaxAge <= 0 || !a.ldapCache.IsStale() {
+		return false
+	}
+
+	return time.Since(a.ldapCache.LastRefresh()) > a.staleServeMaxAge
+}
+
+// rejectStale answers requests reading from the cache with 503 once the data
+// is too old to be trusted. Stale data within the maximum age is still served.
+func (a *App) rejectStale(c *fiber.Ctx) error {
+	if !a.tooStale() {
+		return c.Next()
+	}
+
+	for _, path := range cacheIndependentPaths {
+		if strings.HasPrefix(c.Path(), path) {
+			return c.Next()
+		}
+	}
+
+	c.Status(fiber.StatusServiceUnavailable)
+	c.Set(fiber.HeaderRetryAfter, staleRetrySeconds)
+
+	message := fmt.Sprintf("the directory could not be loaded for more than %s", a.staleServeMaxAge)
+	if strings.HasPrefix(c.Path(), "/api/") {
+		return c.JSON(fiber.Map{"error": message})
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return templates.Stale(message).Render(c.UserContext(), c.Response().BodyWriter())
+}
@@ -0,0 +1,92 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-manager/internal/ldaptest"
+	"github.com/netresearch/ldap-manager/internal/options"
+)
+
+// newStaleTestApp returns an app whose refreshes fail after the first one.
+func newStaleTestApp(t *testing.T, maxAge time.Duration) (*App, string) {
+	t.Helper()
+
+	a, server := newTestApp(t, func(opts *options.Opts) {
+		opts.StaleServeMaxAge = maxAge
+	})
+	cookie := login(t, a, "jdoe", "jdoe")
+
+	server.Intercept(func(req ldaptest.Request) *ldaptest.Result {
+		if req.Operation == "search" {
+			return &ldaptest.Result{Code: goldap.LDAPResultInsufficientAccessRights}
+		}
+
+		return nil
+	})
+
+	go a.ldapCache.Run()
+	t.Cleanup(a.ldapCache.Stop)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !a.ldapCache.IsStale() {
+		if time.Now().After(deadline) {
+			t.Fatal("cache is not stale after a failed refresh")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	return a, cookie
+}
+
+func TestStaleServeWithinMaxAge(t *testing.T) {
+	a, cookie := newStaleTestApp(t, time.Hour)
+
+	res, body := testRequest(t, a, http.MethodGet, "/users", cookie, nil)
+	if res.StatusCode != http.StatusOK || !strings.Contains(body, "Jane Roe") {
+		t.Errorf("users answered with %d, want the stale list", res.StatusCode)
+	}
+
+	_, body = testRequest(t, a, http.MethodGet, "/health", "", nil)
+
+	var health healthResponse
+	if err := json.Unmarshal([]byte(body), &health); err != nil {
+		t.Fatal(err)
+	}
+	if !health.ServingStale || health.Status != "ok" {
+		t.Errorf("health reports serving_stale %v and status %q, want true and ok", health.ServingStale, health.Status)
+	}
+}
+
+func TestStaleServeBeyondMaxAge(t *testing.T) {
+	a, cookie := newStaleTestApp(t, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	res, _ := testRequest(t, a, http.MethodGet, "/users", cookie, nil)
+	if res.StatusCode != http.StatusServiceUnavailable || res.Header.Get(fiber.HeaderRetryAfter) != staleRetrySeconds {
+		t.Errorf("users answered with %d and Retry-After %q, want %d and %s", res.StatusCode, res.Header.Get(fiber.HeaderRetryAfter), http.StatusServiceUnavailable, staleRetrySeconds)
+	}
+
+	res, body := testRequest(t, a, http.MethodGet, "/api/v1/whoami", cookie, nil)
+	if res.StatusCode != http.StatusServiceUnavailable || !strings.Contains(body, `"error"`) {
+		t.Errorf("API answered with %d %s, want a JSON error", res.StatusCode, body)
+	}
+
+	res, body = testRequest(t, a, http.MethodGet, "/health", "", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("health answered with %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	var health healthResponse
+	if err := json.Unmarshal([]byte(body), &health); err != nil {
+		t.Fatal(err)
+	}
+	if !health.ServingStale || health.Status != "degraded" {
+		t.Errorf("health reports serving_stale %v and status %q, want true and degraded", health.ServingStale, health.Status)
+	}
+}
@@ -2,5 +2,5 @@ package static
 
 import "embed"
 
-//go:embed *.css *.png *.ico *.svg *.webp site.webmanifest browserconfig.xml
+//go:embed *.css *.png *.ico *.svg *.webp browserconfig.xml
 var Static embed.FS
@@ -0,0 +1,28 @@
+package templates
+
+import "context"
+
+type Branding struct {
+	Title   string
+	LogoURL string
+}
+
+var defaultBranding = Branding{
+	Title:   "LDAP Manager",
+	LogoURL: "/static/logo.webp",
+}
+
+type brandingKey struct{}
+
+// WithBranding attaches the branding to the context the templates are rendered with.
+func WithBranding(ctx context.Context, branding Branding) context.Context {
+	return context.WithValue(ctx, brandingKey{}, branding)
+}
+
+func brandingFrom(ctx context.Context) Branding {
+	if branding, ok := ctx.Value(brandingKey{}).(Branding); ok {
+		return branding
+	}
+
+	return defaultBranding
+}
@@ -0,0 +1,23 @@
+package templates
+
+import "context"
+
+type partialKey struct{}
+
+// WithPartial marks the context so pages are rendered without the surrounding
+// layout, for requests which only swap the page content, like HTMX requests.
+func WithPartial(ctx context.Context) context.Context {
+	return context.WithValue(ctx, partialKey{}, true)
+}
+
+func isPartial(ctx context.Context) bool {
+	partial, _ := ctx.Value(partialKey{}).(bool)
+
+	return partial
+}
+
+// IsPartial reports whether pages are rendered without the layout, so
+// rendered pages can be cached per variant.
+func IsPartial(ctx context.Context) bool {
+	return isPartial(ctx)
+}
@@ -0,0 +1,62 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// requestTimeout passes a deadline on to the handlers via the user context.
+// LDAP operations are not started anymore once it is exceeded, but running ones
+// can not be interrupted. A read request returning after the deadline is
+// answered with 503 instead of its result. The response of any other request is
+// kept, as it reports a write which might have been applied.
+//
+// The response is only sent once the handler returned: fiber's context can not
+// be used after the handler, so it can not be run in the background. A request
+// can therefore take the timeout plus the duration of the LDAP operation
+// running at the deadline. Operations on connections passed to withDeadline
+// are bounded by the deadline themselves.
+func requestTimeout(timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return err
+		}
+
+		if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+			log.Warn().Err(err).Msgf("request to %s exceeded the timeout of %s", c.Path(), timeout)
+
+			return err
+		}
+
+		log.Warn().Err(err).Msgf("request to %s exceeded the timeout of %s, answering with 503", c.Path(), timeout)
+
+		// Reset drops the headers set by the handler as well, e.g. a
+		// Location or Set-Cookie header.
+		c.Response().Reset()
+		return c.Status(fiber.StatusServiceUnavailable).SendString("The request took too long, please try again later.")
+	}
+}
+
+// withDeadline bounds the requests on a connection by the deadline of ctx, as
+// go-ldap does not take a context.
+func withDeadline(ctx context.Context, conn *goldap.Conn) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+
+	if remaining := time.Until(deadline); remaining > 0 {
+		conn.SetTimeout(remaining)
+	}
+}
@@ -0,0 +1,83 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	const timeout = 10 * time.Millisecond
+
+	handler := func(delay time.Duration) fiber.Handler {
+		return func(c *fiber.Ctx) error {
+			time.Sleep(delay)
+			c.Set(fiber.HeaderLocation, "/users")
+
+			return c.Status(fiber.StatusFound).SendString("result")
+		}
+	}
+
+	f := fiber.New()
+	f.Use(requestTimeout(timeout))
+	f.Get("/fast", handler(0))
+	f.Get("/slow", handler(3*timeout))
+	f.Post("/slow", handler(3*timeout))
+
+	tests := []struct {
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{fiber.MethodGet, "/fast", fiber.StatusFound, "result"},
+		{fiber.MethodGet, "/slow", fiber.StatusServiceUnavailable, "The request took too long, please try again later."},
+		// A write which might have been applied is reported as it is.
+		{fiber.MethodPost, "/slow", fiber.StatusFound, "result"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			res, err := f.Test(httptest.NewRequest(tt.method, tt.path, nil), -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCode != tt.wantStatus || string(body) != tt.wantBody {
+				t.Errorf("answered with %d %q, want %d %q", res.StatusCode, body, tt.wantStatus, tt.wantBody)
+			}
+
+			// The headers of a replaced response are dropped.
+			if res.StatusCode == fiber.StatusServiceUnavailable && res.Header.Get(fiber.HeaderLocation) != "" {
+				t.Errorf("503 kept the Location header %q", res.Header.Get(fiber.HeaderLocation))
+			}
+		})
+	}
+}
+
+func TestNoLDAPOperationAfterTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	started := false
+	err := newBindLimiter(0, 0).do(ctx, func() error {
+		started = true
+
+		return nil
+	})
+
+	if !errors.Is(err, context.DeadlineExceeded) || started {
+		t.Errorf("do() = %v and started the operation: %v", err, started)
+	}
+}
@@ -3,15 +3,17 @@ package web
 import (
 	"net/url"
 	"sort"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/netresearch/ldap-manager/internal/ldap_cache"
 	"github.com/netresearch/ldap-manager/internal/web/templates"
+	"github.com/netresearch/ldap-manager/internal/webhook"
 	ldap "github.com/netresearch/simple-ldap-go"
 )
 
 func (a *App) usersHandler(c *fiber.Ctx) error {
-	sess, err := a.sessionStore.Get(c)
+	sess, err := a.session(c)
 	if err != nil {
 		return handle500(c, err)
 	}
@@ -20,18 +22,18 @@ func (a *App) usersHandler(c *fiber.Ctx) error {
 		return c.Redirect("/login")
 	}
 
-	showDisabled := c.Query("show-disabled", "0") == "1"
+	showDisabled := a.showDisabled(c, sess)
 	users := a.ldapCache.FindUsers(showDisabled)
-	sort.SliceStable(users, func(i, j int) bool {
-		return users[i].CN() < users[j].CN()
-	})
+	if order, ok := sortOrder(c); ok {
+		ldap_cache.SortUsers(users, order)
+	}
 
 	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
-	return templates.Users(users, showDisabled, templates.Flashes()).Render(c.UserContext(), c.Response().BodyWriter())
+	return templates.Users(users, showDisabled, emptyState(len(users), a.ldapCache.Users.Count()), templates.Flashes()).Render(c.UserContext(), c.Response().BodyWriter())
 }
 
 func (a *App) userHandler(c *fiber.Ctx) error {
-	sess, err := a.sessionStore.Get(c)
+	sess, err := a.session(c)
 	if err != nil {
 		return handle500(c, err)
 	}
@@ -60,7 +62,7 @@ func (a *App) userHandler(c *fiber.Ctx) error {
 	})
 
 	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
-	return templates.User(user, unassignedGroups, templates.Flashes()).Render(c.UserContext(), c.Response().BodyWriter())
+	return templates.User(user, unassignedGroups, a.attributesEditable(), templates.Flashes()).Render(c.UserContext(), c.Response().BodyWriter())
 }
 
 type userModifyForm struct {
@@ -69,7 +71,7 @@ type userModifyForm struct {
 }
 
 func (a *App) userModifyHandler(c *fiber.Ctx) error {
-	sess, err := a.sessionStore.Get(c)
+	sess, err := a.session(c)
 	if err != nil {
 		return handle500(c, err)
 	}
@@ -97,7 +99,10 @@ func (a *App) userModifyHandler(c *fiber.Ctx) error {
 		return handle500(c, err)
 	}
 
-	l, err := a.ldapClient.WithCredentials(executor.DN(), sess.Get("password").(string))
+	l, err := a.sessionToLDAPClient(c.UserContext(), sess)
+	if a.credentialsRevoked(err) {
+		return a.expireSession(c, sess)
+	}
 	if err != nil {
 		return handle500(c, err)
 	}
@@ -116,27 +121,63 @@ func (a *App) userModifyHandler(c *fiber.Ctx) error {
 		return unassignedGroups[i].CN() < unassignedGroups[j].CN()
 	})
 
+	groupDN := form.AddGroup
+	if groupDN == nil {
+		groupDN = form.RemoveGroup
+	}
+
+	if err := a.validateDNs(a.findGroup, *groupDN); err != nil {
+		c.Status(fiber.StatusBadRequest)
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return templates.User(
+			user, unassignedGroups, a.attributesEditable(), templates.Flashes(
+				templates.ErrorFlash("Failed to modify: "+err.Error()),
+			),
+		).Render(c.UserContext(), c.Response().BodyWriter())
+	}
+
 	if form.AddGroup != nil {
-		if err := l.AddUserToGroup(userDN, *form.AddGroup); err != nil {
+		if err := a.addUserToGroup(c.UserContext(), l, userDN, *form.AddGroup); err != nil {
+			if a.credentialsRevoked(err) {
+				return a.expireSession(c, sess)
+			}
+
+			c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
 			return templates.User(
-				user, unassignedGroups, templates.Flashes(
-					templates.ErrorFlash("Failed to modify: "+err.Error()),
+				user, unassignedGroups, a.attributesEditable(), templates.Flashes(
+					templates.ErrorFlash("Failed to modify: "+explainLDAPError(err)),
 				),
 			).Render(c.UserContext(), c.Response().BodyWriter())
 		}
 
-		a.ldapCache.OnAddUserToGroup(userDN, *form.AddGroup)
+		a.webhook.Notify(webhook.Event{
+			Operation: webhook.OperationAddUserToGroup,
+			Actor:     executor.DN(),
+			Target:    userDN,
+			Group:     *form.AddGroup,
+			Timestamp: time.Now(),
+		})
 	} else if form.RemoveGroup != nil {
-		if err := l.RemoveUserFromGroup(userDN, *form.RemoveGroup); err != nil {
+		if err := a.removeUserFromGroup(c.UserContext(), l, userDN, *form.RemoveGroup); err != nil {
+			if a.credentialsRevoked(err) {
+				return a.expireSession(c, sess)
+			}
+
 			c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
 			return templates.User(
-				user, unassignedGroups, templates.Flashes(
-					templates.ErrorFlash("Failed to modify: "+err.Error()),
+				user, unassignedGroups, a.attributesEditable(), templates.Flashes(
+					templates.ErrorFlash("Failed to modify: "+explainLDAPError(err)),
 				),
 			).Render(c.UserContext(), c.Response().BodyWriter())
 		}
 
-		a.ldapCache.OnRemoveUserFromGroup(userDN, *form.RemoveGroup)
+		a.webhook.Notify(webhook.Event{
+			Operation: webhook.OperationRemoveUserFromGroup,
+			Actor:     executor.DN(),
+			Target:    userDN,
+			Group:     *form.RemoveGroup,
+			Timestamp: time.Now(),
+		})
 	}
 
 	thinUser, err = a.ldapCache.FindUserByDN(userDN)
@@ -155,7 +196,7 @@ func (a *App) userModifyHandler(c *fiber.Ctx) error {
 
 	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
 	return templates.User(
-		user, unassignedGroups, templates.Flashes(
+		user, unassignedGroups, a.attributesEditable(), templates.Flashes(
 			templates.SuccessFlash("Successfully modified user"),
 		),
 	).Render(c.UserContext(), c.Response().BodyWriter())
@@ -172,3 +213,22 @@ func (a *App) findUnassignedGroups(user *ldap_cache.FullLDAPUser) []ldap.Group {
 		return true
 	})
 }
+
+func (a *App) renderUser(c *fiber.Ctx, userDN string, flashes []templates.Flash) error {
+	thinUser, err := a.ldapCache.FindUserByDN(userDN)
+	if err != nil {
+		return handle500(c, err)
+	}
+
+	user := a.ldapCache.PopulateGroupsForUser(thinUser)
+	sort.SliceStable(user.Groups, func(i, j int) bool {
+		return user.Groups[i].CN() < user.Groups[j].CN()
+	})
+	unassignedGroups := a.findUnassignedGroups(user)
+	sort.SliceStable(unassignedGroups, func(i, j int) bool {
+		return unassignedGroups[i].CN() < unassignedGroups[j].CN()
+	})
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return templates.User(user, unassignedGroups, a.attributesEditable(), flashes).Render(c.UserContext(), c.Response().BodyWriter())
+}
@@ -0,0 +1,34 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestUserShowsUnresolvedGroups(t *testing.T) {
+	a, server := newTestApp(t, nil)
+	cookie := login(t, a, "jdoe", "jdoe")
+
+	// The group is not cached yet when the user's memberships are.
+	server.AddGroup("cn=new,ou=groups,dc=example,dc=com", testUserDN)
+	if err := a.ldapCache.RefreshUsers(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, body := testRequest(t, a, http.MethodGet, "/users/"+url.PathEscape(testUserDN), cookie, nil)
+	if want := "1 group memberships could not be resolved."; !strings.Contains(body, want) {
+		t.Errorf("user page does not contain %q", want)
+	}
+
+	// Once the group is cached, the warning is gone.
+	if err := a.ldapCache.RefreshGroups(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, body = testRequest(t, a, http.MethodGet, "/users/"+url.PathEscape(testUserDN), cookie, nil)
+	if strings.Contains(body, "could not be resolved") {
+		t.Error("user page warns about resolved group memberships")
+	}
+}
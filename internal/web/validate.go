@@ -0,0 +1,46 @@
+package web
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/netresearch/ldap-manager/internal/options"
+	"github.com/rs/zerolog/log"
+)
+
+// validateDNs checks that DNs submitted with a modification refer to entries
+// known to the cache, before anything is sent to the LDAP server. Unknown DNs
+// either reject the modification or are only logged, as configured.
+func (a *App) validateDNs(find func(dn string) error, dns ...string) error {
+	unknown := make([]string, 0)
+	for _, dn := range dns {
+		if err := find(dn); err != nil {
+			unknown = append(unknown, dn)
+		}
+	}
+
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	err := fmt.Errorf("no such entry: %s", strings.Join(unknown, "; "))
+	if a.dnValidation == options.DNValidationWarn {
+		log.Warn().Err(err).Msg("modifying with a DN which is not in the cache")
+
+		return nil
+	}
+
+	return err
+}
+
+func (a *App) findUser(dn string) error {
+	_, err := a.ldapCache.FindUserByDN(dn)
+
+	return err
+}
+
+func (a *App) findGroup(dn string) error {
+	_, err := a.ldapCache.FindGroupByDN(dn)
+
+	return err
+}
@@ -0,0 +1,73 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/netresearch/ldap-manager/internal/ldaptest"
+	"github.com/netresearch/ldap-manager/internal/options"
+)
+
+func TestValidateDNs(t *testing.T) {
+	const unknownDN = "cn=typo,ou=groups,dc=example,dc=com"
+
+	tests := []struct {
+		name       string
+		validation options.DNValidation
+		target     string
+		form       url.Values
+		wantStatus int
+		wantWrites int
+	}{
+		{"known group", options.DNValidationReject, "/users/" + url.PathEscape(testOtherDN), url.Values{"addgroup": {testGroupDN}}, http.StatusOK, 1},
+		{"unknown group", options.DNValidationReject, "/users/" + url.PathEscape(testOtherDN), url.Values{"addgroup": {unknownDN}}, http.StatusBadRequest, 0},
+		{"unknown group to remove", options.DNValidationReject, "/users/" + url.PathEscape(testUserDN), url.Values{"removegroup": {unknownDN}}, http.StatusBadRequest, 0},
+		{"unknown member", options.DNValidationReject, "/groups/" + url.PathEscape(testGroupDN), url.Values{"adduser": {"cn=typo,ou=users,dc=example,dc=com"}}, http.StatusBadRequest, 0},
+		{"unknown group with warnings", options.DNValidationWarn, "/users/" + url.PathEscape(testOtherDN), url.Values{"addgroup": {unknownDN}}, http.StatusOK, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, server := newTestApp(t, func(opts *options.Opts) {
+				opts.DNValidation = tt.validation
+			})
+			cookie := login(t, a, "jdoe", "jdoe")
+
+			res, body := testRequest(t, a, http.MethodPost, tt.target, cookie, tt.form)
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("modification answered with %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if got := server.Count("modify"); got != tt.wantWrites {
+				t.Errorf("sent %d modifications, want %d", got, tt.wantWrites)
+			}
+
+			if tt.wantStatus == http.StatusBadRequest && !strings.Contains(body, "no such entry: cn=typo") {
+				t.Error("the rejection does not list the unknown DN")
+			}
+		})
+	}
+}
+
+func TestUserModifyFailureIsHTML(t *testing.T) {
+	a, server := newTestApp(t, nil)
+	cookie := login(t, a, "jdoe", "jdoe")
+
+	server.Intercept(func(req ldaptest.Request) *ldaptest.Result {
+		if req.Operation == "modify" {
+			return &ldaptest.Result{Code: goldap.LDAPResultInsufficientAccessRights}
+		}
+
+		return nil
+	})
+
+	res, body := testRequest(t, a, http.MethodPost, "/users/"+url.PathEscape(testOtherDN), cookie, url.Values{"addgroup": {testGroupDN}})
+	if got := res.Header.Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want HTML", got)
+	}
+	if !strings.Contains(body, "You are not allowed to modify this group.") {
+		t.Error("the failure is not explained")
+	}
+}
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-manager/internal/web/templates"
+)
+
+// warmupRetrySeconds is how long clients are asked to wait before retrying
+// a request which arrived before the cache was warmed up.
+const warmupRetrySeconds = "5"
+
+// cacheIndependentPaths are served before the cache is warmed up, since they
+// do not read from it.
+var cacheIndependentPaths = []string{
+	"/health",
+	"/static/",
+	"/favicon.ico",
+	"/site.webmanifest",
+	"/login",
+	"/logout",
+	"/api/v1/auth/verify",
+}
+
+// waitForWarmup answers requests with 503 until the LDAP cache has been filled
+// once, instead of rendering empty lists. Browsers get a page which reloads
+// itself, API clients a JSON error.
+func (a *App) waitForWarmup(c *fiber.Ctx) error {
+	if a.ldapCache.IsWarmedUp() {
+		return c.Next()
+	}
+
+	for _, path := range cacheIndependentPaths {
+		if strings.HasPrefix(c.Path(), path) {
+			return c.Next()
+		}
+	}
+
+	c.Status(fiber.StatusServiceUnavailable)
+	c.Set(fiber.HeaderRetryAfter, warmupRetrySeconds)
+
+	if strings.HasPrefix(c.Path(), "/api/") {
+		return c.JSON(fiber.Map{"error": "starting up, please retry later"})
+	}
+
+	c.Set("Refresh", warmupRetrySeconds)
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return templates.WarmingUp().Render(c.UserContext(), c.Response().BodyWriter())
+}
@@ -0,0 +1,44 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWaitForWarmup(t *testing.T) {
+	a, _ := newColdTestApp(t, nil)
+
+	// Signing in does not need the cache.
+	cookie := login(t, a, "jdoe", "jdoe")
+
+	res, body := testRequest(t, a, http.MethodGet, "/users", cookie, nil)
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("users answered with %d before the warmup, want %d", res.StatusCode, http.StatusServiceUnavailable)
+	}
+	if res.Header.Get("Refresh") != warmupRetrySeconds || !strings.Contains(body, "Starting up, please wait") {
+		t.Error("users did not answer with the reloading warmup page")
+	}
+
+	res, body = testRequest(t, a, http.MethodGet, "/api/v1/whoami", cookie, nil)
+	if res.StatusCode != http.StatusServiceUnavailable || !strings.Contains(body, `"error"`) {
+		t.Errorf("API answered with %d %s before the warmup, want a JSON error", res.StatusCode, body)
+	}
+
+	res, _ = testRequest(t, a, http.MethodGet, "/health", "", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("health answered with %d before the warmup, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	if err := a.ldapCache.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	res, body = testRequest(t, a, http.MethodGet, "/users", cookie, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("users answered with %d after the warmup, want %d", res.StatusCode, http.StatusOK)
+	}
+	if !strings.Contains(body, "Jane Roe") {
+		t.Error("users does not list the users after the warmup")
+	}
+}
@@ -0,0 +1,184 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	queueSize     = 128
+	sendTimeout   = 5 * time.Second
+	sendAttempts  = 3
+	retryInterval = time.Second
+
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of the request body,
+	// prefixed with "sha256=".
+	SignatureHeader = "X-Signature-256"
+)
+
+type Operation string
+
+const (
+	OperationAddUserToGroup      Operation = "add_user_to_group"
+	OperationRemoveUserFromGroup Operation = "remove_user_from_group"
+	OperationModifyAttributes    Operation = "modify_attributes"
+)
+
+type Event struct {
+	Operation Operation `json:"operation"`
+	Actor     string    `json:"actor"`
+	Target    string    `json:"target"`
+	Group     string    `json:"group"`
+	// Attributes lists the names of the modified attributes. Their values
+	// are not sent, as they may be confidential.
+	Attributes []string  `json:"attributes,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// Notifier posts events to a webhook URL from a background worker, so
+// callers never wait for the receiving end. A nil notifier discards all events.
+type Notifier struct {
+	url    string
+	secret string
+	client *http.Client
+	queue  chan Event
+
+	// m guards stopped, so no event is sent to the queue after it was closed.
+	m       sync.Mutex
+	stopped bool
+	dropped atomic.Uint64
+}
+
+// New returns a notifier posting to url. The payload is signed when secret is not empty.
+func New(url, secret string) *Notifier {
+	return &Notifier{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: sendTimeout},
+		queue:  make(chan Event, queueSize),
+	}
+}
+
+// Run delivers queued events until Stop is called.
+func (n *Notifier) Run() {
+	if n == nil {
+		return
+	}
+
+	for event := range n.queue {
+		if err := n.deliver(event); err != nil {
+			log.Error().Err(err).Msgf("could not deliver webhook for %s on %s", event.Operation, event.Target)
+		}
+	}
+}
+
+// Stop lets Run return once the queued events are delivered. Events passed to
+// Notify afterwards, e.g. by handlers outliving the shutdown timeout, are dropped.
+func (n *Notifier) Stop() {
+	if n == nil {
+		return
+	}
+
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	if n.stopped {
+		return
+	}
+
+	n.stopped = true
+	close(n.queue)
+}
+
+// Notify queues the event for delivery. The event is dropped when the queue is
+// full or the notifier is stopped.
+func (n *Notifier) Notify(event Event) {
+	if n == nil {
+		return
+	}
+
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	if n.stopped {
+		n.dropped.Add(1)
+		log.Warn().Msgf("webhook notifier is stopped, dropping %s event for %s", event.Operation, event.Target)
+
+		return
+	}
+
+	select {
+	case n.queue <- event:
+	default:
+		n.dropped.Add(1)
+		log.Warn().Msgf("webhook queue is full, dropping %s event for %s", event.Operation, event.Target)
+	}
+}
+
+// Dropped returns the amount of events that were not queued for delivery.
+func (n *Notifier) Dropped() uint64 {
+	if n == nil {
+		return 0
+	}
+
+	return n.dropped.Load()
+}
+
+func (n *Notifier) deliver(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = n.send(body)
+		if err == nil || attempt == sendAttempts {
+			return err
+		}
+
+		log.Debug().Err(err).Msgf("webhook delivery attempt %d failed, retrying", attempt)
+		time.Sleep(time.Duration(attempt) * retryInterval)
+	}
+}
+
+func (n *Notifier) send(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if n.secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(n.secret, body))
+	}
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of body using secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
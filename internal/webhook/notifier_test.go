@@ -0,0 +1,158 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type received struct {
+	body      []byte
+	signature string
+}
+
+func newReceiver(t *testing.T) (*httptest.Server, chan received) {
+	t.Helper()
+
+	ch := make(chan received, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("could not read body: %v", err)
+		}
+
+		ch <- received{body: body, signature: r.Header.Get(SignatureHeader)}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, ch
+}
+
+func TestNotifierDeliversSignedPayload(t *testing.T) {
+	srv, ch := newReceiver(t)
+
+	n := New(srv.URL, "secret")
+	go n.Run()
+	defer n.Stop()
+
+	event := Event{
+		Operation: OperationAddUserToGroup,
+		Actor:     "cn=admin,dc=example,dc=com",
+		Target:    "cn=user,dc=example,dc=com",
+		Group:     "cn=group,dc=example,dc=com",
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	n.Notify(event)
+
+	var got received
+	select {
+	case got = <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+
+	var payload Event
+	if err := json.Unmarshal(got.body, &payload); err != nil {
+		t.Fatalf("payload is not valid JSON: %v", err)
+	}
+	if !reflect.DeepEqual(payload, event) {
+		t.Errorf("payload = %+v, want %+v", payload, event)
+	}
+
+	if want := "sha256=" + Sign("secret", got.body); got.signature != want {
+		t.Errorf("signature = %q, want %q", got.signature, want)
+	}
+}
+
+func TestNotifierWithoutSecretIsUnsigned(t *testing.T) {
+	srv, ch := newReceiver(t)
+
+	n := New(srv.URL, "")
+	go n.Run()
+	defer n.Stop()
+
+	n.Notify(Event{Operation: OperationRemoveUserFromGroup})
+
+	select {
+	case got := <-ch:
+		if got.signature != "" {
+			t.Errorf("signature = %q, want none", got.signature)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+}
+
+func TestEventAttributes(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		want  string
+	}{
+		{
+			name:  "membership",
+			event: Event{Operation: OperationAddUserToGroup},
+			want:  `{"operation":"add_user_to_group","actor":"","target":"","group":"","timestamp":"0001-01-01T00:00:00Z"}`,
+		},
+		{
+			name:  "attributes",
+			event: Event{Operation: OperationModifyAttributes, Attributes: []string{"mail", "telephoneNumber"}},
+			want:  `{"operation":"modify_attributes","actor":"","target":"","group":"","attributes":["mail","telephoneNumber"],"timestamp":"0001-01-01T00:00:00Z"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("payload = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotifyAfterStop(t *testing.T) {
+	n := New("http://127.0.0.1:0", "")
+	n.Stop()
+
+	// Must neither panic nor block.
+	n.Notify(Event{Operation: OperationAddUserToGroup})
+	n.Stop()
+
+	if dropped := n.Dropped(); dropped != 1 {
+		t.Errorf("Dropped() = %d, want 1", dropped)
+	}
+}
+
+func TestNotifyDropsWhenQueueIsFull(t *testing.T) {
+	n := New("http://127.0.0.1:0", "")
+	defer n.Stop()
+
+	for i := 0; i < queueSize+2; i++ {
+		n.Notify(Event{Operation: OperationAddUserToGroup})
+	}
+
+	if dropped := n.Dropped(); dropped != 2 {
+		t.Errorf("Dropped() = %d, want 2", dropped)
+	}
+}
+
+func TestNilNotifier(t *testing.T) {
+	var n *Notifier
+
+	n.Notify(Event{})
+	n.Stop()
+	n.Run()
+
+	if dropped := n.Dropped(); dropped != 0 {
+		t.Errorf("Dropped() = %d, want 0", dropped)
+	}
+}
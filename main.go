@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/netresearch/ldap-manager/internal"
 	"github.com/netresearch/ldap-manager/internal/options"
@@ -11,19 +16,71 @@ import (
 )
 
 func main() {
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	if len(os.Args) > 1 && os.Args[1] == "request-log" {
+		os.Exit(filterRequestLog(os.Args[2:]))
+	}
 
-	log.Info().Msgf("LDAP Manager %s starting...", internal.FormatVersion())
+	// Parsing the options already logs, e.g. which env files were loaded.
+	log.Logger = newLogger(options.EarlyLogFormat())
 
 	opts := options.Parse()
-	log.Logger = log.Logger.Level(opts.LogLevel)
+	log.Logger = newLogger(opts.LogFormat).Level(opts.LogLevel)
+
+	log.Info().Msgf("LDAP Manager %s starting...", internal.FormatVersion())
+	if opts.LogConfigSummary {
+		opts.LogSummary(log.Logger)
+	}
 
 	app, err := web.NewApp(opts)
 	if err != nil {
 		log.Fatal().Err(err).Msg("could not initialize web app")
 	}
 
-	if err := app.Listen(":3000"); err != nil {
-		log.Fatal().Err(err).Msg("could not start web server")
+	go func() {
+		if err := app.Listen(":3000"); err != nil {
+			log.Fatal().Err(err).Msg("could not start web server")
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	log.Info().Msgf("Shutting down, waiting up to %s for open requests...", opts.ShutdownTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.ShutdownTimeout)
+	defer cancel()
+
+	if err := app.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("could not shut down gracefully")
 	}
 }
+
+func newLogger(format options.LogFormat) zerolog.Logger {
+	if format == options.LogFormatJSON {
+		return zerolog.New(os.Stderr).With().Timestamp().Logger()
+	}
+
+	return zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger()
+}
+
+// filterRequestLog implements "ldap-manager request-log", which reads a JSON
+// log from stdin and prints the request log entries matching the flags.
+func filterRequestLog(args []string) int {
+	fs := flag.NewFlagSet("request-log", flag.ContinueOnError)
+	var filter web.RequestLogFilter
+	fs.StringVar(&filter.User, "user", "", "Only show requests of the user with this DN.")
+	fs.StringVar(&filter.RequestID, "request-id", "", "Only show the request with this X-Request-ID.")
+	fs.StringVar(&filter.PathPrefix, "path", "", "Only show requests whose path starts with this prefix.")
+	fs.IntVar(&filter.Status, "status", 0, "Only show requests answered with this status.")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := web.FilterRequestLog(os.Stdin, os.Stdout, filter); err != nil {
+		fmt.Fprintf(os.Stderr, "could not filter the request log: %v\n", err)
+		return 1
+	}
+
+	return 0
+}